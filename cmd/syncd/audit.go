@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/audit"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+func runAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to config file (default: searched as for the daemon)")
+	logPath := fs.String("log", "", "Audit log to read (default: audit_log from the config)")
+	entry := fs.String("entry", "", "Only records whose entry contains this text")
+	client := fs.String("client", "", "Only records for this client or originating from it")
+	since := fs.String("since", "", "Only records at or after this time (RFC 3339, or a duration like 24h meaning that long ago)")
+	until := fs.String("until", "", "Only records at or before this time (RFC 3339 or a duration ago)")
+	output := fs.String("output", "text", "Output format: text or json")
+	_ = fs.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		log.Fatalf("unknown -output %q (want text or json)", *output)
+	}
+	path := *logPath
+	if path == "" {
+		_, cfg := loadConfig(*configPath)
+		if cfg.AuditLog == "" {
+			log.Fatalf("audit log is disabled in the config")
+		}
+		path = cfg.AuditLog
+	}
+	filter := audit.Filter{Entry: *entry, Client: *client}
+	var err error
+	if filter.Since, err = parseTimeFlag(*since); err != nil {
+		log.Fatalf("-since: %v", err)
+	}
+	if filter.Until, err = parseTimeFlag(*until); err != nil {
+		log.Fatalf("-until: %v", err)
+	}
+
+	records, err := audit.Read(path, filter)
+	if err != nil {
+		log.Fatalf("audit error: %v", err)
+	}
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			enc.Encode(r)
+		}
+	case "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tRUN\tCLIENT\tLIST\tACTION\tENTRY\tORIGIN")
+		for _, r := range records {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Time.Local().Format(time.DateTime), r.RunID, r.Client, r.List, r.Action, r.Entry, dash(r.Origin))
+		}
+		tw.Flush()
+	}
+}
+
+// parseTimeFlag accepts an RFC 3339 time or a duration before now.
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// loadConfig finds (when path is empty) and loads the config, exiting on
+// error, and returns its path.
+func loadConfig(path string) (string, config.Config) {
+	if path == "" {
+		found, err := config.Find()
+		if err != nil {
+			log.Fatalf("config error: %v", err)
+		}
+		path = found
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	return path, cfg
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/daemon"
+)
+
+var ctlCommands = map[string]struct {
+	method   string
+	endpoint string
+}{
+	"status": {http.MethodGet, "/v1/status"},
+	"policy": {http.MethodGet, "/v1/policy"},
+	"sync":   {http.MethodPost, "/v1/sync"},
+	"pause":  {http.MethodPost, "/v1/pause"},
+	"resume": {http.MethodPost, "/v1/resume"},
+	"reload": {http.MethodPost, "/v1/reload"},
+}
+
+func runCtl(args []string) {
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	configPath := fs.String("config", "", "Config of the daemon to control (default: searched as for the daemon)")
+	socket := fs.String("socket", "", "Control socket (default: control_socket from the config)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: syncd ctl [flags] status|policy|sync|pause|resume|reload")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cmd, ok := ctlCommands[fs.Arg(0)]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := *socket
+	if path == "" {
+		configFile, cfg := loadConfig(*configPath)
+		path = controlSocket(cfg, configFile)
+		if path == "" {
+			log.Fatalf("control socket is disabled in the config")
+		}
+	}
+	body, err := daemon.Call(path, cmd.method, cmd.endpoint)
+	os.Stdout.Write(body)
+	if err != nil {
+		log.Fatalf("%s: %v", fs.Arg(0), err)
+	}
+}
+
+// controlSocket returns the daemon's control socket for the config, or "" when
+// control_socket is off.
+func controlSocket(cfg config.Config, configPath string) string {
+	switch cfg.ControlSocket {
+	case "":
+		return daemon.SocketPath(cfg.StateDir, configPath)
+	case config.ControlSocketOff:
+		return ""
+	default:
+		return cfg.ControlSocket
+	}
+}
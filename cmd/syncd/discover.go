@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+func runDiscover(args []string) {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	home := fs.String("home", "", "Home directory to probe (defaults to the current user's)")
+	goos := fs.String("os", runtime.GOOS, "Probe the locations used on this OS")
+	write := fs.String("write", "", "Also write a config for the detected tools to this path")
+	force := fs.Bool("force", false, "Overwrite the -write file if it exists")
+	_ = fs.Parse(args)
+
+	found, explicit := discover(*home, *goos)
+	printDiscovery(found)
+	if *write != "" {
+		writeGeneratedConfig(*write, found, explicit, *force)
+	}
+}
+
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	home := fs.String("home", "", "Home directory to probe (defaults to the current user's)")
+	goos := fs.String("os", runtime.GOOS, "Probe the locations used on this OS")
+	out := fs.String("o", config.DefaultPath(), "Path of the config file to write")
+	force := fs.Bool("force", false, "Overwrite the config file if it exists")
+	_ = fs.Parse(args)
+
+	found, explicit := discover(*home, *goos)
+	printDiscovery(found)
+	writeGeneratedConfig(*out, found, explicit, *force)
+}
+
+// discover probes the preset locations under home for goos. explicit reports
+// whether those differ from the locations presets resolve to when the config
+// is loaded, so the generated config has to spell out each path.
+func discover(home string, goos string) (found []sync.Discovery, explicit bool) {
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("resolve home dir: %v", err)
+	}
+	if home == "" {
+		home = userHome
+	}
+	explicit = filepath.Clean(home) != filepath.Clean(userHome) || goos != runtime.GOOS
+	return sync.Discover(home, goos), explicit
+}
+
+func printDiscovery(found []sync.Discovery) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tSTATUS\tALLOW\tDENY\tPATH")
+	for _, d := range found {
+		status := "missing"
+		allow, deny := "-", "-"
+		switch {
+		case d.Err != nil:
+			status = "error: " + d.Err.Error()
+		case d.Found:
+			status = "found"
+			allow = fmt.Sprint(d.Allow)
+			deny = fmt.Sprint(d.Deny)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Preset.Name, status, allow, deny, d.Client.AllowPath)
+	}
+	tw.Flush()
+}
+
+func writeGeneratedConfig(path string, found []sync.Discovery, explicit bool, force bool) {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			log.Fatalf("%s already exists (use -force to overwrite)", path)
+		}
+	}
+	content, n := generateConfig(found, explicit)
+	if n == 0 {
+		log.Fatalf("no supported tools found; nothing written")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Fatalf("create config dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		log.Fatalf("write config: %v", err)
+	}
+	fmt.Fprintf(os.Stdout, "wrote %s with %d clients\n", path, n)
+}
+
+// generateConfig renders detected tools as preset clients, with the probed
+// path of each when explicit is set. MCP-only presets go in their own group so
+// they are never merged with command lists.
+func generateConfig(found []sync.Discovery, explicit bool) (string, int) {
+	var commands, mcp []sync.Discovery
+	for _, d := range found {
+		if !d.Found {
+			continue
+		}
+		if len(d.Preset.Accepts) == 1 && d.Preset.Accepts[0] == "mcp" {
+			mcp = append(mcp, d)
+		} else {
+			commands = append(commands, d)
+		}
+	}
+	client := func(sb *strings.Builder, indent string, d sync.Discovery) {
+		fmt.Fprintf(sb, "%s- preset: %s\n", indent, d.Preset.Name)
+		if explicit {
+			fmt.Fprintf(sb, "%s  allow_path: %s\n", indent, d.Client.AllowPath)
+		}
+		fmt.Fprintf(sb, "%s  missing_ok: true\n", indent)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by syncd init on %s.\n", time.Now().Format("2006-01-02"))
+	sb.WriteString("# Run `syncd -once -dry-run` to preview the merged policy before syncing.\n")
+	sb.WriteString("version: 1\nmode: union\n")
+	if len(commands) > 0 {
+		sb.WriteString("\nclients:\n")
+		for _, d := range commands {
+			client(&sb, "  ", d)
+		}
+	}
+	if len(mcp) > 0 {
+		sb.WriteString("\ngroups:\n  - name: mcp\n    mode: union\n    clients:\n")
+		for _, d := range mcp {
+			client(&sb, "      ", d)
+		}
+	}
+	return sb.String(), len(commands) + len(mcp)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/lock"
+)
+
+// acquireInstance takes the daemon lock for configPath in stateDir and
+// records this process's PID in it, so a second daemon for the same config
+// fails fast instead of racing the first.
+func acquireInstance(stateDir string, configPath string) (*lock.Lock, error) {
+	path := lock.PathFor(filepath.Join(stateDir, "instances"), configPath)
+	l, err := lock.TryAcquire(path)
+	if errors.Is(err, lock.ErrLocked) {
+		if b, readErr := os.ReadFile(path); readErr == nil {
+			if pid, convErr := strconv.Atoi(strings.TrimSpace(string(b))); convErr == nil {
+				return nil, fmt.Errorf("another syncd (pid %d) is already running for %s", pid, configPath)
+			}
+		}
+		return nil, fmt.Errorf("another syncd is already running for %s", configPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("instance lock: %w", err)
+	}
+	f := l.File()
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return l, nil
+}
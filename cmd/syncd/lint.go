@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/daemon"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/guard"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+var severityRank = map[sync.Severity]int{sync.SeverityInfo: 1, sync.SeverityWarning: 2, sync.SeverityError: 3}
+
+func runLint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to config file (default: searched as for the daemon)")
+	output := flags.String("output", "text", "Output format: text, json or sarif")
+	failOn := flags.String("fail-on", "error", "Exit with status 1 on findings of this severity or worse: error, warning, info or none")
+	_ = flags.Parse(args)
+
+	threshold, ok := severityRank[sync.Severity(*failOn)]
+	if !ok && *failOn != "none" {
+		log.Fatalf("unknown -fail-on %q (want error, warning, info or none)", *failOn)
+	}
+
+	_, cfg := loadConfig(*configPath)
+	// Report guardrail hits as findings instead of failing on them.
+	cfg.Guardrails.Action = guard.Block
+	opts := daemon.Options(cfg, true)
+	opts.AuditLog = ""
+	result, err := sync.Run(cfg, opts)
+	if err != nil {
+		log.Fatalf("lint: %v", err)
+	}
+	problems := sync.Lint(cfg, result)
+
+	switch *output {
+	case "json":
+		if problems == nil {
+			problems = sync.Problems{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			OK       bool          `json:"ok"`
+			Problems sync.Problems `json:"problems"`
+		}{len(problems.Errors()) == 0, problems})
+	case "sarif":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(sarifReport(problems))
+	case "text":
+		counts := map[sync.Severity]int{}
+		for _, p := range problems {
+			fmt.Fprintln(os.Stdout, p.String())
+			counts[p.Severity]++
+		}
+		if len(problems) == 0 {
+			fmt.Fprintln(os.Stdout, "no problems found")
+		} else {
+			fmt.Fprintf(os.Stdout, "%d error(s), %d warning(s), %d info\n", counts[sync.SeverityError], counts[sync.SeverityWarning], counts[sync.SeverityInfo])
+		}
+	default:
+		log.Fatalf("unknown -output %q (want text, json or sarif)", *output)
+	}
+	if ok {
+		for _, p := range problems {
+			if severityRank[p.Severity] >= threshold {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// SARIF 2.1.0, as far as code scanning tools need it.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	DefaultConfig    struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func sarifLevel(s sync.Severity) string {
+	if s == sync.SeverityInfo {
+		return "note"
+	}
+	return string(s)
+}
+
+func sarifReport(problems sync.Problems) sarifLog {
+	driver := sarifDriver{Name: "syncd", InformationURI: "https://github.com/hongkongkiwi/codex-claude-allow-deny-sync"}
+	for _, r := range sync.LintRules {
+		rule := sarifRule{ID: r.ID, ShortDescription: sarifMessage{r.Description}}
+		rule.DefaultConfig.Level = sarifLevel(r.Severity)
+		driver.Rules = append(driver.Rules, rule)
+	}
+	results := []sarifResult{}
+	wd, _ := os.Getwd()
+	for _, p := range problems {
+		res := sarifResult{RuleID: p.Rule, Level: sarifLevel(p.Severity), Message: sarifMessage{p.Message}}
+		if p.File != "" {
+			var loc sarifLocation
+			uri := p.File
+			if rel, err := filepath.Rel(wd, p.File); err == nil && filepath.IsLocal(rel) {
+				uri = rel
+			}
+			loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(uri)
+			if p.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: p.Line, StartColumn: p.Column}
+			}
+			res.Locations = []sarifLocation{loc}
+		}
+		results = append(results, res)
+	}
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
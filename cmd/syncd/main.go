@@ -1,59 +1,265 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/daemon"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/guard"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/service"
 	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "presets":
+			runPresets(os.Args[2:])
+			return
+		case "discover":
+			runDiscover(os.Args[2:])
+			return
+		case "init":
+			runInit(os.Args[2:])
+			return
+		case "schema":
+			runSchema(os.Args[2:])
+			return
+		case "audit":
+			runAudit(os.Args[2:])
+			return
+		case "status":
+			runStatus(os.Args[2:])
+			return
+		case "lint":
+			runLint(os.Args[2:])
+			return
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "ctl":
+			runCtl(os.Args[2:])
+			return
+		case "service":
+			runService(os.Args[2:])
+			return
+		}
+	}
+
 	var (
-		configPath = flag.String("config", "syncd.yaml", "Path to config file")
-		once       = flag.Bool("once", false, "Run one sync and exit")
-		dryRun     = flag.Bool("dry-run", false, "Compute merged lists without writing changes")
-		validate   = flag.Bool("validate", false, "Validate config and exit")
-		interval   = flag.Duration("interval", 30*time.Second, "Sync interval")
+		configPath  = flag.String("config", "", "Path to config file (default: $SYNCD_CONFIG, $XDG_CONFIG_HOME/syncd/syncd.yaml, ~/.config/syncd/syncd.yaml, ./syncd.yaml)")
+		once        = flag.Bool("once", false, "Run one sync and exit")
+		dryRun      = flag.Bool("dry-run", false, "Compute merged lists without writing changes")
+		validate    = flag.Bool("validate", false, "Validate config and exit")
+		output      = flag.String("output", "text", "Validation output format: text or json")
+		interval    = flag.Duration("interval", 30*time.Second, "Sync interval")
+		metricsAddr = flag.String("metrics-addr", "", "Serve Prometheus metrics at http://ADDR/metrics while running as a daemon (e.g. 127.0.0.1:9464)")
+		logLevel    = flag.String("log-level", "info", "Log level: debug, info, warn or error")
+		logFormat   = flag.String("log-format", "text", "Log format on stderr: text or json")
 	)
 	flag.Parse()
 
+	logger, err := newLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	if *configPath == "" {
+		path, err := config.Find()
+		if err != nil {
+			fatal("config error", "error", err)
+		}
+		*configPath = path
+	}
 	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("config error: %v", err)
+		if *validate && *output == "json" {
+			var problems sync.Problems
+			for _, line := range strings.Split(err.Error(), "\n") {
+				problems = append(problems, sync.Problem{Severity: sync.SeverityError, Message: line})
+			}
+			os.Exit(reportProblems(problems, *output))
+		}
+		fatal("config error", "config", *configPath, "error", err)
 	}
 
 	if *validate {
-		if err := sync.Validate(cfg); err != nil {
-			log.Fatalf("validation error: %v", err)
-		}
-		fmt.Fprintln(os.Stdout, "config ok")
-		return
+		os.Exit(reportProblems(sync.Check(cfg), *output))
 	}
 
+	opts := daemon.Options(cfg, *dryRun)
+	opts.Logger = logger
+
 	if *once {
-		policy, err := sync.Run(cfg, sync.Options{DryRun: *dryRun})
+		// Run logs its own summary or failure.
+		result, err := sync.Run(cfg, opts)
 		if err != nil {
-			log.Fatalf("sync error: %v", err)
+			os.Exit(1)
 		}
 		if *dryRun {
-			fmt.Fprintf(os.Stdout, "dry run complete (allow=%d, deny=%d)\n", len(policy.Allow), len(policy.Deny))
+			fmt.Fprintln(os.Stdout, "dry run complete")
+			for _, group := range result.Groups {
+				fmt.Fprintf(os.Stdout, "group %s (allow=%d, deny=%d)\n", group.Name, len(group.Policy.Allow), len(group.Policy.Deny))
+				for _, snap := range group.Clients {
+					line := fmt.Sprintf("  %s: %s", snap.Client.Name, describeDirection(snap.Client))
+					if snap.Client.Writes() {
+						line += fmt.Sprintf(" (allow=%d, deny=%d)", len(snap.Target.Allow), len(snap.Target.Deny))
+					}
+					if len(snap.Client.Accepts) > 0 {
+						line += ", accepts " + strings.Join(snap.Client.Accepts, ", ")
+					}
+					fmt.Fprintln(os.Stdout, line)
+				}
+			}
+			for _, project := range result.Projects {
+				fmt.Fprintf(os.Stdout, "project %s: %s (allow=%d, deny=%d)\n", project.Dir, relPath(project.Dir, project.Client.AllowPath), len(project.Target.Allow), len(project.Target.Deny))
+			}
+			for _, b := range result.Blocked {
+				fmt.Fprintln(os.Stdout, describeBlocked(b))
+			}
+			for _, c := range result.Collapsed {
+				name := c.Client
+				if c.Group == "" {
+					name = "project " + c.Client
+				} else if c.Group != config.DefaultGroup {
+					name = c.Group + "/" + c.Client
+				}
+				fmt.Fprintf(os.Stdout, "collapsed %s %s into %s for %s\n", c.List, c.Entry, c.By, name)
+			}
 		} else {
 			fmt.Fprintln(os.Stdout, "sync complete")
 		}
 		return
 	}
 
-	ticker := time.NewTicker(*interval)
-	defer ticker.Stop()
+	instance, err := acquireInstance(cfg.StateDir, *configPath)
+	if err != nil {
+		fatal(err.Error())
+	}
+	defer instance.Release()
+
+	var stats *daemonMetrics
+	if *metricsAddr != "" {
+		stats = newDaemonMetrics()
+		if err := serveMetrics(*metricsAddr, stats); err != nil {
+			fatal("metrics", "addr", *metricsAddr, "error", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := daemon.New(*configPath, cfg, *interval)
+	d.DryRun = *dryRun
+	d.Logger = logger
+	d.AfterRun = func(out daemon.Outcome) {
+		if stats != nil {
+			stats.observe(out.Result, out.Err, out.Duration)
+		}
+		if out.Err != nil {
+			service.Notify("STATUS=last sync failed: " + out.Err.Error())
+		} else {
+			service.Notify(fmt.Sprintf("STATUS=last sync %s, %d change(s)", out.Start.Format(time.RFC3339), len(out.Result.Changes)))
+		}
+	}
+	if socket := controlSocket(cfg, *configPath); socket != "" {
+		closeControl, err := d.ServeControl(socket)
+		if err != nil {
+			fatal("control socket", "path", socket, "error", err)
+		}
+		defer closeControl()
+	}
+	logger.Info("daemon started", "config", *configPath, "interval", *interval, "dry_run", *dryRun, "pid", os.Getpid())
+	service.Notify("READY=1")
+	if wd := service.WatchdogInterval(); wd > 0 {
+		go watchdog(ctx, d, wd)
+	}
+	d.Loop(ctx)
+	service.Notify("STOPPING=1")
+	logger.Info("daemon stopped")
+}
+
+func describeBlocked(b sync.Blocked) string {
+	line := "blocked " + b.Entry
+	if b.Origin != "" {
+		line += " from " + b.Origin
+	}
+	if b.Project != "" {
+		line += " in project " + b.Project
+	} else {
+		line += " in group " + b.Group
+	}
+	action := "dropped"
+	if b.Action == guard.Deny {
+		action = "moved to deny"
+	}
+	return fmt.Sprintf("%s: %s, %s (%s)", line, b.Rule, b.Reason, action)
+}
 
-	for {
-		if _, err := sync.Run(cfg, sync.Options{DryRun: *dryRun}); err != nil {
-			log.Printf("sync error: %v", err)
+func describeDirection(client config.Client) string {
+	switch {
+	case client.Reads() && client.Writes():
+		return "read/write"
+	case client.Reads():
+		return "read only (never written)"
+	case client.Writes():
+		return "write only (does not contribute)"
+	default:
+		return "ignored"
+	}
+}
+
+func relPath(base string, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// reportProblems prints validation problems and returns the exit code: 1 when
+// any problem is an error.
+func reportProblems(problems sync.Problems, output string) int {
+	code := 0
+	if len(problems.Errors()) > 0 {
+		code = 1
+	}
+	switch output {
+	case "json":
+		if problems == nil {
+			problems = sync.Problems{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			OK       bool          `json:"ok"`
+			Problems sync.Problems `json:"problems"`
+		}{code == 0, problems}); err != nil {
+			log.Fatalf("encode problems: %v", err)
+		}
+	case "text":
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p.String())
+		}
+		if code == 0 {
+			fmt.Fprintln(os.Stdout, "config ok")
+		} else {
+			fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems.Errors()))
 		}
-		<-ticker.C
+	default:
+		log.Fatalf("unknown -output %q (want text or json)", output)
 	}
+	return code
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/audit"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/metrics"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+// projectClient labels the metrics of all workspace project files, so each
+// project does not become its own series.
+const projectClient = "workspace"
+
+type daemonMetrics struct {
+	reg            *metrics.Registry
+	runs           *metrics.Vec
+	clientFailures *metrics.Vec
+	entries        *metrics.Vec
+	added          *metrics.Vec
+	removed        *metrics.Vec
+	lastSuccess    *metrics.Vec
+	duration       *metrics.Vec
+	blocked        *metrics.Vec
+}
+
+func newDaemonMetrics() *daemonMetrics {
+	reg := metrics.NewRegistry()
+	return &daemonMetrics{
+		reg:            reg,
+		runs:           reg.Counter("syncd_runs_total", "Sync runs by result.", "result"),
+		clientFailures: reg.Counter("syncd_client_failures_total", "Failed runs caused by reading or writing a client.", "group", "client"),
+		entries:        reg.Gauge("syncd_entries", "Entries per client and list after the last successful run.", "group", "client", "list"),
+		added:          reg.Counter("syncd_entries_added_total", "Entries added to clients.", "group", "client", "list"),
+		removed:        reg.Counter("syncd_entries_removed_total", "Entries removed from clients.", "group", "client", "list"),
+		lastSuccess:    reg.Gauge("syncd_last_success_timestamp_seconds", "Unix time of the last successful run."),
+		duration:       reg.Gauge("syncd_run_duration_seconds", "Duration of the last run."),
+		blocked:        reg.Counter("syncd_guardrail_blocked_total", "Allow entries stopped by a guardrail.", "rule", "action"),
+	}
+}
+
+func (m *daemonMetrics) observe(result sync.Result, err error, elapsed time.Duration) {
+	m.duration.Set(elapsed.Seconds())
+	blocked := result.Blocked
+	var guardErr *sync.GuardError
+	if errors.As(err, &guardErr) {
+		blocked = guardErr.Blocked
+	}
+	for _, b := range blocked {
+		m.blocked.Add(1, b.Rule, b.Action)
+	}
+	if err != nil {
+		m.runs.Add(1, "failure")
+		var clientErr *sync.ClientError
+		if errors.As(err, &clientErr) {
+			m.clientFailures.Add(1, clientErr.Group, clientErr.Client)
+		}
+		return
+	}
+	m.runs.Add(1, "success")
+	m.lastSuccess.Set(float64(time.Now().UnixNano()) / 1e9)
+
+	m.entries.Reset()
+	for _, group := range result.Groups {
+		for _, snap := range group.Clients {
+			policy := snap.Policy
+			if snap.Client.Writes() {
+				policy = snap.Target
+			}
+			m.entries.Set(float64(len(policy.Allow)), group.Name, snap.Client.Name, "allow")
+			m.entries.Set(float64(len(policy.Deny)), group.Name, snap.Client.Name, "deny")
+		}
+	}
+	for _, c := range result.Changes {
+		client := c.Client
+		if c.Group == "" {
+			client = projectClient
+		}
+		if c.Action == audit.Added {
+			m.added.Add(1, c.Group, client, c.List)
+		} else {
+			m.removed.Add(1, c.Group, client, c.List)
+		}
+	}
+}
+
+// serveMetrics listens on addr before returning, so a bad address fails at
+// startup, and serves /metrics in the background.
+func serveMetrics(addr string, m *daemonMetrics) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.reg.Handler())
+	go http.Serve(ln, mux)
+	return nil
+}
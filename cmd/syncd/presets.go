@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+func runPresets(args []string) {
+	fs := flag.NewFlagSet("presets", flag.ExitOnError)
+	goos := fs.String("os", runtime.GOOS, "Show paths for this OS (darwin, linux, windows)")
+	_ = fs.Parse(args)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tFORMAT\tPATH\tALLOW KEY\tDENY KEY\tACCEPTS")
+	for _, p := range config.Presets() {
+		accepts := "all"
+		if len(p.Accepts) > 0 {
+			accepts = strings.Join(p.Accepts, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Name, p.Format, p.Path(*goos), dash(p.AllowKey), dash(p.DenyKey), accepts)
+	}
+	tw.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
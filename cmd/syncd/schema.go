@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+func runSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := fs.String("o", "", "Write the schema to this file instead of stdout")
+	_ = fs.Parse(args)
+
+	b, err := config.JSONSchema()
+	if err != nil {
+		log.Fatalf("schema: %v", err)
+	}
+	if *out == "" {
+		os.Stdout.Write(b)
+		return
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Fatalf("write schema: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/daemon"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/service"
+)
+
+func runService(args []string) {
+	usage := "usage: syncd service install|uninstall|status [flags]"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	configPath := fs.String("config", "", "Config the service runs with (default: searched as for the daemon)")
+	name := fs.String("name", service.DefaultName, "Service name, to run several configs side by side")
+	system := fs.String("system", defaultServiceSystem(), "Service manager: systemd or launchd")
+	binary := fs.String("binary", "", "syncd binary the service runs (default: this executable)")
+	interval := fs.Duration("interval", 0, "Sync interval passed to the daemon (default: the daemon's default)")
+	noStart := fs.Bool("no-start", false, "Only write (or remove) the unit file; do not enable, start or stop it")
+	printOnly := fs.Bool("print", false, "Print the unit file instead of installing it")
+	_ = fs.Parse(args[1:])
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("resolve home dir: %v", err)
+	}
+	unit := service.Unit{Name: *name, Interval: *interval}
+	path, err := unit.Path(*system, home, config.ExpandPath("$XDG_CONFIG_HOME", home))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch action {
+	case "install":
+		cfgFile, _ := loadConfig(*configPath)
+		if unit.Config, err = filepath.Abs(cfgFile); err != nil {
+			log.Fatal(err)
+		}
+		unit.Binary = *binary
+		if unit.Binary == "" {
+			unit.Binary = currentExecutable()
+		}
+		content := unit.Systemd()
+		if *system == service.Launchd {
+			content = unit.Launchd(home)
+		}
+		if *printOnly {
+			fmt.Print(content)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("wrote %s\n", path)
+		if *noStart {
+			return
+		}
+		if *system == service.Launchd {
+			mustRun("launchctl", "load", "-w", path)
+		} else {
+			mustRun("systemctl", "--user", "daemon-reload")
+			mustRun("systemctl", "--user", "enable", "--now", *name+".service")
+		}
+	case "uninstall":
+		if !*noStart {
+			// Stopping fails when the service is not loaded; removing the file still proceeds.
+			if *system == service.Launchd {
+				runCommand("launchctl", "unload", "-w", path)
+			} else {
+				runCommand("systemctl", "--user", "disable", "--now", *name+".service")
+			}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		fmt.Printf("removed %s\n", path)
+		if !*noStart && *system == service.Systemd {
+			runCommand("systemctl", "--user", "daemon-reload")
+		}
+	case "status":
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("%s: not installed\n", path)
+			os.Exit(3)
+		}
+		fmt.Printf("%s: installed\n", path)
+		var err error
+		if *system == service.Launchd {
+			err = runCommand("launchctl", "list", unit.Label())
+		} else {
+			err = runCommand("systemctl", "--user", "status", "--no-pager", *name+".service")
+		}
+		if exit, ok := err.(*exec.ExitError); ok {
+			os.Exit(exit.ExitCode())
+		} else if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func defaultServiceSystem() string {
+	if runtime.GOOS == "darwin" {
+		return service.Launchd
+	}
+	return service.Systemd
+}
+
+// currentExecutable returns this binary's resolved path, refusing the
+// temporary binaries built by go run.
+func currentExecutable() string {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("locate syncd binary: %v (use -binary)", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	if strings.Contains(exe, "go-build") {
+		log.Fatalf("%s is a temporary go run binary; install syncd or pass -binary", exe)
+	}
+	return exe
+}
+
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func mustRun(name string, args ...string) {
+	if err := runCommand(name, args...); err != nil {
+		log.Fatalf("%s %s: %v", name, strings.Join(args, " "), err)
+	}
+}
+
+// watchdog pings the service manager at half the watchdog interval as long
+// as no run has been in progress for longer than the interval, so a hung run
+// gets the daemon restarted.
+func watchdog(ctx context.Context, d *daemon.Daemon, interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if since := d.RunningSince(); since.IsZero() || time.Since(since) < interval {
+				service.Notify("WATCHDOG=1")
+			}
+		}
+	}
+}
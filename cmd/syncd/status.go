@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/daemon"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+func runStatus(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to config file (default: searched as for the daemon)")
+	output := flags.String("output", "text", "Output format: text or json")
+	_ = flags.Parse(args)
+
+	_, cfg := loadConfig(*configPath)
+	var last *daemon.Status
+	if cfg.StatusFile != "" {
+		st, err := daemon.ReadStatus(cfg.StatusFile)
+		switch {
+		case err == nil:
+			last = &st
+		case !errors.Is(err, fs.ErrNotExist):
+			log.Printf("status file: %v", err)
+		}
+	}
+	drift, err := readDrift(cfg)
+	if err != nil {
+		log.Fatalf("status: %v", err)
+	}
+
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Daemon  *daemon.Status     `json:"daemon"`
+			InSync  bool               `json:"in_sync"`
+			Clients []sync.ClientDrift `json:"clients"`
+		}{last, countDrifted(drift) == 0, drift})
+	case "text":
+		if last != nil {
+			printDaemonStatus(os.Stdout, *last)
+		}
+		printDrift(os.Stdout, drift, true)
+	default:
+		log.Fatalf("unknown -output %q (want text or json)", *output)
+	}
+}
+
+// runCheck exits with status 1, printing the differences, when a sync would
+// change any client.
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to config file (default: searched as for the daemon)")
+	output := flags.String("output", "text", "Output format: text or json")
+	_ = flags.Parse(args)
+
+	_, cfg := loadConfig(*configPath)
+	code, err := check(cfg, *output, os.Stdout)
+	if err != nil {
+		log.Fatalf("check: %v", err)
+	}
+	os.Exit(code)
+}
+
+// check writes the drift of cfg's clients to w and returns the exit code:
+// 1 when a sync would change any client, 0 otherwise.
+func check(cfg config.Config, output string, w io.Writer) (int, error) {
+	if output != "text" && output != "json" {
+		return 0, fmt.Errorf("unknown -output %q (want text or json)", output)
+	}
+	drift, err := readDrift(cfg)
+	if err != nil {
+		return 0, err
+	}
+	drifted := countDrifted(drift)
+	if output == "json" {
+		var clients []sync.ClientDrift
+		for _, d := range drift {
+			if !d.InSync() {
+				clients = append(clients, d)
+			}
+		}
+		if clients == nil {
+			clients = []sync.ClientDrift{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			InSync  bool               `json:"in_sync"`
+			Clients []sync.ClientDrift `json:"clients"`
+		}{drifted == 0, clients})
+	} else {
+		printDrift(w, drift, false)
+	}
+	if drifted > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// readDrift merges the config in memory and returns each written client's
+// drift from the result. Nothing is written.
+func readDrift(cfg config.Config) ([]sync.ClientDrift, error) {
+	opts := daemon.Options(cfg, true)
+	opts.AuditLog = ""
+	result, err := sync.Run(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return sync.Drift(result), nil
+}
+
+func countDrifted(drift []sync.ClientDrift) int {
+	n := 0
+	for _, d := range drift {
+		if !d.InSync() {
+			n++
+		}
+	}
+	return n
+}
+
+func printDaemonStatus(w io.Writer, st daemon.Status) {
+	switch {
+	case st.LastRun == nil:
+		fmt.Fprintln(w, "daemon: no run yet")
+	case st.LastRun.Error != "":
+		fmt.Fprintf(w, "daemon: last run %s failed: %s\n", st.LastRun.Start.Local().Format("2006-01-02 15:04:05"), st.LastRun.Error)
+	default:
+		fmt.Fprintf(w, "daemon: last run %s (%s), %d change(s)\n", st.LastRun.Start.Local().Format("2006-01-02 15:04:05"), st.LastRun.Trigger, st.LastRun.Changes)
+	}
+	if st.Paused {
+		fmt.Fprintln(w, "daemon: paused")
+	}
+}
+
+// printDrift prints one line per client, followed by its missing (+) and
+// extra (-) entries. Clients in sync are only listed when all is set.
+func printDrift(w io.Writer, drift []sync.ClientDrift, all bool) {
+	for _, d := range drift {
+		name := driftName(d)
+		if d.InSync() {
+			if all {
+				fmt.Fprintf(w, "%s: in sync\n", name)
+			}
+			continue
+		}
+		missing := len(d.Missing.Allow) + len(d.Missing.Deny)
+		extra := len(d.Extra.Allow) + len(d.Extra.Deny)
+		fmt.Fprintf(w, "%s: %d missing, %d extra\n", name, missing, extra)
+		for _, line := range []struct {
+			sign, list string
+			entries    []string
+		}{
+			{"+", "allow", d.Missing.Allow},
+			{"+", "deny", d.Missing.Deny},
+			{"-", "allow", d.Extra.Allow},
+			{"-", "deny", d.Extra.Deny},
+		} {
+			for _, e := range line.entries {
+				fmt.Fprintf(w, "  %s %s %s\n", line.sign, line.list, e)
+			}
+		}
+	}
+	if n := countDrifted(drift); n > 0 {
+		fmt.Fprintf(w, "%d of %d client(s) drifted\n", n, len(drift))
+	} else {
+		fmt.Fprintf(w, "all %d client(s) in sync\n", len(drift))
+	}
+}
+
+func driftName(d sync.ClientDrift) string {
+	switch d.Group {
+	case "":
+		return "project " + d.Client
+	case config.DefaultGroup:
+		return d.Client
+	default:
+		return d.Group + "/" + d.Client
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+func TestCheckAllowOnlyClientInSync(t *testing.T) {
+	dir := t.TempDir()
+	claude := filepath.Join(dir, "claude.json")
+	cursor := filepath.Join(dir, "cursor.json")
+	if err := os.WriteFile(claude, []byte("{\n  \"allow\": [\n    \"ls\"\n  ],\n  \"deny\": [\n    \"Bash(rm:*)\"\n  ]\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cursor, []byte("{\n  \"allow\": [\n    \"ls\"\n  ]\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{
+		StateDir: filepath.Join(dir, "state"),
+		Clients: []config.Client{
+			{Name: "claude", Format: "json-object", AllowPath: claude, AllowKey: "allow", DenyKey: "deny"},
+			{Name: "cursor", Format: "json-object", AllowPath: cursor, AllowKey: "allow"},
+		},
+	}
+	var out bytes.Buffer
+	code, err := check(cfg, "text", &out)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if code != 0 {
+		t.Fatalf("exit code %d, want 0:\n%s", code, out.String())
+	}
+
+	if err := os.WriteFile(cursor, []byte("{\n  \"allow\": []\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, err = check(cfg, "json", &out)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if code != 1 {
+		t.Fatalf("exit code %d after cursor lost its entry, want 1", code)
+	}
+}
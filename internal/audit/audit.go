@@ -0,0 +1,117 @@
+// Package audit appends policy changes to a JSON Lines log and queries it.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	Added   = "added"
+	Removed = "removed"
+)
+
+// Record is one entry added to or removed from one list of one client.
+// Origin names the client the entry was read from.
+type Record struct {
+	Time   time.Time `json:"time"`
+	RunID  string    `json:"run_id"`
+	Group  string    `json:"group,omitempty"`
+	Client string    `json:"client"`
+	File   string    `json:"file"`
+	List   string    `json:"list"`
+	Entry  string    `json:"entry"`
+	Action string    `json:"action"`
+	Origin string    `json:"origin,omitempty"`
+}
+
+// Append writes records to the log at path, one JSON object per line,
+// creating the file and its directory if needed.
+func Append(path string, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter selects records. Entry matches as a substring; Client matches the
+// client or the originating client; zero times leave the range open.
+type Filter struct {
+	Entry  string
+	Client string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f Filter) Match(r Record) bool {
+	if f.Entry != "" && !strings.Contains(r.Entry, f.Entry) {
+		return false
+	}
+	if f.Client != "" && r.Client != f.Client && r.Origin != f.Client {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the records in the log at path matching filter, oldest first.
+// A missing log has no records.
+func Read(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if filter.Match(r) {
+			out = append(out, r)
+		}
+	}
+	return out, scanner.Err()
+}
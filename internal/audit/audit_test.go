@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "audit.jsonl")
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := Append(path, []Record{
+		{Time: start, RunID: "r1", Client: "claude", List: "allow", Entry: "Bash(git status)", Action: Added, Origin: "cursor"},
+		{Time: start, RunID: "r1", Client: "cursor", List: "deny", Entry: "Bash(rm -rf:*)", Action: Removed},
+	}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := Append(path, []Record{
+		{Time: start.Add(time.Hour), RunID: "r2", Client: "gemini", List: "allow", Entry: "Bash(git log)", Action: Added, Origin: "claude"},
+	}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	cases := []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"r1", "r1", "r2"}},
+		{Filter{Entry: "git"}, []string{"r1", "r2"}},
+		{Filter{Client: "cursor"}, []string{"r1", "r1"}},
+		{Filter{Since: start.Add(time.Minute)}, []string{"r2"}},
+		{Filter{Until: start}, []string{"r1", "r1"}},
+	}
+	for _, tc := range cases {
+		records, err := Read(path, tc.filter)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var got []string
+		for _, r := range records {
+			got = append(got, r.RunID)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("filter %+v: got %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+
+	records, err := Read(filepath.Join(t.TempDir(), "missing.jsonl"), Filter{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("missing log: %v %v", records, err)
+	}
+}
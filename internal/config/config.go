@@ -3,60 +3,244 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
-
-	"gopkg.in/yaml.v3"
+	"time"
 )
 
 type Config struct {
-	Mode    string   `yaml:"mode"`
-	Source  string   `yaml:"source"`
-	Sort    *bool    `yaml:"sort"`
-	Clients []Client `yaml:"clients"`
+	Version       int           `yaml:"version"`
+	Include       []string      `yaml:"include"`
+	Templates     []Client      `yaml:"templates"`
+	Mode          string        `yaml:"mode"`
+	Source        string        `yaml:"source"`
+	Sort          *bool         `yaml:"sort"`
+	Dedupe        *bool         `yaml:"dedupe"`
+	Clients       []Client      `yaml:"clients"`
+	Groups        []Group       `yaml:"groups"`
+	Workspaces    []Workspace   `yaml:"workspaces"`
+	StateDir      string        `yaml:"state_dir"`
+	LockTimeout   time.Duration `yaml:"lock_timeout"`
+	AuditLog      string        `yaml:"audit_log"`
+	ControlSocket string        `yaml:"control_socket"`
+	StatusFile    string        `yaml:"status_file"`
+	Guardrails    Guardrails    `yaml:"guardrails"`
+	Pos           Positions     `yaml:"-"`
+}
+
+// DefaultStateDir holds the daemon's instance and file locks, the audit log
+// and the status file, unless state_dir is set. Lock waits give up after
+// DefaultLockTimeout unless lock_timeout is set. AuditLogOff as audit_log
+// disables the audit log, ControlSocketOff as control_socket disables the
+// control API, and StatusFileOff as status_file disables the status file.
+const (
+	DefaultStateDir    = "$XDG_STATE_HOME/syncd"
+	DefaultLockTimeout = 10 * time.Second
+	AuditLogOff        = "off"
+	ControlSocketOff   = "off"
+	StatusFileOff      = "off"
+)
+
+type Group struct {
+	Name    string    `yaml:"name"`
+	Mode    string    `yaml:"mode"`
+	Source  string    `yaml:"source"`
+	Sort    *bool     `yaml:"sort"`
+	Dedupe  *bool     `yaml:"dedupe"`
+	Clients []Client  `yaml:"clients"`
+	Pos     Positions `yaml:"-"`
+}
+
+// Workspace describes directories holding projects with their own settings
+// files. Each discovered file receives the user policy of Group, or the
+// project policy given by Allow/Deny when Policy is "project".
+type Workspace struct {
+	Roots    []string  `yaml:"roots"`
+	MaxDepth int       `yaml:"max_depth"`
+	Files    []string  `yaml:"files"`
+	AllowKey string    `yaml:"allow_key"`
+	DenyKey  string    `yaml:"deny_key"`
+	Policy   string    `yaml:"policy"`
+	Group    string    `yaml:"group"`
+	Mode     string    `yaml:"mode"`
+	Allow    []string  `yaml:"allow"`
+	Deny     []string  `yaml:"deny"`
+	Pos      Positions `yaml:"-"`
+}
+
+// Guardrails stop dangerous allow entries from being synced. The built-in
+// rules apply unless Builtin is false; Patterns add rules and Exempt lists
+// entries that are never blocked.
+type Guardrails struct {
+	Action   string    `yaml:"action"`
+	Builtin  *bool     `yaml:"builtin"`
+	Patterns []string  `yaml:"patterns"`
+	Exempt   []string  `yaml:"exempt"`
+	Pos      Positions `yaml:"-"`
+}
+
+const (
+	WorkspacePolicyUser    = "user"
+	WorkspacePolicyProject = "project"
+)
+
+var DefaultWorkspaceFiles = []string{
+	".claude/settings.json",
+	".claude/settings.local.json",
+	".cursor/cli.json",
 }
 
+// DefaultGroup names the group formed by the top-level mode, source and clients.
+const DefaultGroup = "default"
+
 type Client struct {
-	Name      string `yaml:"name"`
-	AllowPath string `yaml:"allow_path"`
-	DenyPath  string `yaml:"deny_path"`
-	Format    string `yaml:"format"`
-	AllowKey  string `yaml:"allow_key"`
-	DenyKey   string `yaml:"deny_key"`
-	MissingOK bool   `yaml:"missing_ok"`
+	Name      string    `yaml:"name"`
+	Preset    string    `yaml:"preset"`
+	Extends   string    `yaml:"extends"`
+	AllowPath string    `yaml:"allow_path"`
+	DenyPath  string    `yaml:"deny_path"`
+	Format    string    `yaml:"format"`
+	AllowKey  string    `yaml:"allow_key"`
+	DenyKey   string    `yaml:"deny_key"`
+	MissingOK bool      `yaml:"missing_ok"`
+	Direction string    `yaml:"direction"`
+	Accepts   []string  `yaml:"accepts"`
+	Dedupe    *bool     `yaml:"dedupe"`
+	Pos       Positions `yaml:"-"`
+}
+
+const (
+	DirectionRead  = "read"
+	DirectionWrite = "write"
+	DirectionBoth  = "both"
+)
+
+func (c Client) Reads() bool {
+	d := strings.ToLower(c.Direction)
+	return d == "" || d == DirectionRead || d == DirectionBoth
+}
+
+func (c Client) Writes() bool {
+	d := strings.ToLower(c.Direction)
+	return d == "" || d == DirectionWrite || d == DirectionBoth
+}
+
+// AllGroups returns the top-level clients as the default group followed by the
+// named groups. Named groups without their own sort or dedupe setting inherit
+// the top-level one.
+func (c Config) AllGroups() []Group {
+	groups := make([]Group, 0, len(c.Groups)+1)
+	if len(c.Clients) > 0 {
+		groups = append(groups, Group{
+			Name:    DefaultGroup,
+			Mode:    c.Mode,
+			Source:  c.Source,
+			Sort:    c.Sort,
+			Dedupe:  c.Dedupe,
+			Clients: c.Clients,
+			Pos:     c.Pos,
+		})
+	}
+	for _, g := range c.Groups {
+		if g.Sort == nil {
+			g.Sort = c.Sort
+		}
+		if g.Dedupe == nil {
+			g.Dedupe = c.Dedupe
+		}
+		groups = append(groups, g)
+	}
+	return groups
 }
 
 func Load(path string) (Config, error) {
-	b, err := os.ReadFile(path)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Config{}, fmt.Errorf("resolve home dir: %w", err)
+	}
+	cfg, err := loadFile(path, home, nil)
 	if err != nil {
-		return Config{}, fmt.Errorf("read config: %w", err)
+		return Config{}, err
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return Config{}, fmt.Errorf("parse config: %w", err)
+	if err := resolveTemplates(&cfg); err != nil {
+		return Config{}, err
 	}
-	if len(cfg.Clients) == 0 {
+	total := len(cfg.Clients)
+	for _, g := range cfg.Groups {
+		total += len(g.Clients)
+	}
+	if total == 0 && len(cfg.Workspaces) == 0 {
 		return Config{}, fmt.Errorf("config has no clients")
 	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return Config{}, fmt.Errorf("resolve home dir: %w", err)
+	if err := applyPresets(cfg.Clients); err != nil {
+		return Config{}, err
+	}
+	for i := range cfg.Groups {
+		if err := applyPresets(cfg.Groups[i].Clients); err != nil {
+			return Config{}, fmt.Errorf("group %s: %w", cfg.Groups[i].Name, err)
+		}
 	}
-	for i := range cfg.Clients {
-		cfg.Clients[i].AllowPath = expandHome(cfg.Clients[i].AllowPath, home)
-		cfg.Clients[i].DenyPath = expandHome(cfg.Clients[i].DenyPath, home)
+	if err := expandClients(cfg.Clients, home); err != nil {
+		return Config{}, err
+	}
+	for i := range cfg.Groups {
+		if err := expandClients(cfg.Groups[i].Clients, home); err != nil {
+			return Config{}, fmt.Errorf("group %s: %w", cfg.Groups[i].Name, err)
+		}
+	}
+	for i := range cfg.Workspaces {
+		for j := range cfg.Workspaces[i].Roots {
+			if cfg.Workspaces[i].Roots[j], err = expandPath(cfg.Workspaces[i].Roots[j], home); err != nil {
+				return Config{}, fmt.Errorf("workspace %d roots: %w", i+1, err)
+			}
+		}
+	}
+	if cfg.StateDir == "" {
+		cfg.StateDir = DefaultStateDir
+	}
+	if cfg.StateDir, err = expandPath(cfg.StateDir, home); err != nil {
+		return Config{}, fmt.Errorf("state_dir: %w", err)
+	}
+	switch cfg.AuditLog {
+	case "":
+		cfg.AuditLog = filepath.Join(cfg.StateDir, "audit.jsonl")
+	case AuditLogOff:
+		cfg.AuditLog = ""
+	default:
+		if cfg.AuditLog, err = expandPath(cfg.AuditLog, home); err != nil {
+			return Config{}, fmt.Errorf("audit_log: %w", err)
+		}
+	}
+	if cfg.ControlSocket != ControlSocketOff {
+		if cfg.ControlSocket, err = expandPath(cfg.ControlSocket, home); err != nil {
+			return Config{}, fmt.Errorf("control_socket: %w", err)
+		}
+	}
+	switch cfg.StatusFile {
+	case "":
+		cfg.StatusFile = filepath.Join(cfg.StateDir, "status.json")
+	case StatusFileOff:
+		cfg.StatusFile = ""
+	default:
+		if cfg.StatusFile, err = expandPath(cfg.StatusFile, home); err != nil {
+			return Config{}, fmt.Errorf("status_file: %w", err)
+		}
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = DefaultLockTimeout
 	}
 	return cfg, nil
 }
 
-func expandHome(path string, home string) string {
-	if path == "" {
-		return path
-	}
-	if path == "~" {
-		return home
-	}
-	if strings.HasPrefix(path, "~/") {
-		return strings.Replace(path, "~", home, 1)
+func expandClients(clients []Client, home string) error {
+	for i := range clients {
+		var err error
+		if clients[i].AllowPath, err = expandPath(clients[i].AllowPath, home); err != nil {
+			return fmt.Errorf("client %s allow_path: %w", clients[i].Name, err)
+		}
+		if clients[i].DenyPath, err = expandPath(clients[i].DenyPath, home); err != nil {
+			return fmt.Errorf("client %s deny_path: %w", clients[i].Name, err)
+		}
 	}
-	return path
+	return nil
 }
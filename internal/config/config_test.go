@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExpandHome(t *testing.T) {
@@ -32,3 +34,324 @@ func TestExpandHome(t *testing.T) {
 		t.Fatalf("deny_path not expanded: %s", cfg.Clients[0].DenyPath)
 	}
 }
+
+func TestLoadGroups(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "syncd.yaml")
+	input := []byte(`sort: false
+clients:
+  - name: claude
+    format: newline
+    allow_path: /tmp/allow.txt
+    deny_path: /tmp/deny.txt
+groups:
+  - name: mcp
+    mode: authoritative
+    source: qwen
+    clients:
+      - name: qwen
+        format: json-object
+        allow_path: ~/.qwen/settings.json
+        allow_key: mcp.allowed
+`)
+	if err := os.WriteFile(cfgPath, input, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	groups := cfg.AllGroups()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	if groups[0].Name != DefaultGroup || groups[1].Name != "mcp" {
+		t.Fatalf("unexpected group names: %s, %s", groups[0].Name, groups[1].Name)
+	}
+	if groups[1].Sort == nil || *groups[1].Sort {
+		t.Fatalf("mcp group should inherit sort: false")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("home: %v", err)
+	}
+	if groups[1].Clients[0].AllowPath != filepath.Join(home, ".qwen/settings.json") {
+		t.Fatalf("group allow_path not expanded: %s", groups[1].Clients[0].AllowPath)
+	}
+}
+
+func TestLoadPreset(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "syncd.yaml")
+	input := []byte(`clients:
+  - preset: kilocode
+  - name: work-claude
+    preset: claude
+    allow_path: /tmp/claude.json
+    deny_key: permissions.ask
+`)
+	if err := os.WriteFile(cfgPath, input, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("home: %v", err)
+	}
+	kilo := cfg.Clients[0]
+	if kilo.Name != "kilocode" || kilo.Format != "json-object" || kilo.AllowKey != "autoApproval.execute.allowed" {
+		t.Fatalf("kilocode preset not applied: %+v", kilo)
+	}
+	if kilo.AllowPath != filepath.Join(home, ".kilocode/config.json") || kilo.DenyPath != "" {
+		t.Fatalf("kilocode paths not applied: %s, %s", kilo.AllowPath, kilo.DenyPath)
+	}
+	claude := cfg.Clients[1]
+	if claude.Name != "work-claude" || claude.AllowPath != "/tmp/claude.json" || claude.DenyPath != "" {
+		t.Fatalf("explicit fields should override preset: %+v", claude)
+	}
+	if claude.AllowKey != "permissions.allow" || claude.DenyKey != "permissions.ask" {
+		t.Fatalf("preset keys not merged: %+v", claude)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("clients:\n  - preset: nope\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Fatal("expected unknown preset to fail")
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := "/home/test"
+	t.Setenv("SYNCD_TEST_DIR", "/opt/tools")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("APPDATA", "/appdata")
+
+	cases := map[string]string{
+		"~":                            home,
+		"~/a.json":                     home + "/a.json",
+		"$SYNCD_TEST_DIR/a.json":       "/opt/tools/a.json",
+		"${SYNCD_TEST_DIR}/a.json":     "/opt/tools/a.json",
+		"$XDG_CONFIG_HOME/x/a.json":    filepath.Join(home, ".config") + "/x/a.json",
+		"${HOME}/a.json":               os.Getenv("HOME") + "/a.json",
+		"%APPDATA%/Code/settings.json": "/appdata/Code/settings.json",
+		"%SYNCD_UNSET_VAR%/a.json":     "%SYNCD_UNSET_VAR%/a.json",
+		"/abs/path.json":               "/abs/path.json",
+		"~syncd-no-such-user/a.json":   "~syncd-no-such-user/a.json",
+	}
+	for in, want := range cases {
+		if got := ExpandPath(in, home); got != want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := t.TempDir()
+	xdgPath := filepath.Join(dir, "syncd", "syncd.yaml")
+	if err := os.MkdirAll(filepath.Dir(xdgPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(xdgPath, []byte("clients: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SYNCD_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	got, err := Find()
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got != xdgPath {
+		t.Fatalf("expected %s, got %s", xdgPath, got)
+	}
+
+	t.Setenv("SYNCD_CONFIG", "/explicit/syncd.yaml")
+	got, err = Find()
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got != "/explicit/syncd.yaml" {
+		t.Fatalf("SYNCD_CONFIG should win, got %s", got)
+	}
+}
+
+func TestLoadIncludeAndExtends(t *testing.T) {
+	dir := t.TempDir()
+	base := []byte(`mode: authoritative
+source: claude
+templates:
+  - name: json-perms
+    format: json-object
+    allow_key: permissions.allow
+    deny_key: permissions.deny
+    missing_ok: true
+  - name: local
+    extends: json-perms
+    direction: write
+clients:
+  - name: claude
+    extends: json-perms
+    allow_path: /tmp/claude.json
+  - name: cursor
+    extends: json-perms
+    allow_path: /tmp/cursor.json
+`)
+	if err := os.WriteFile(filepath.Join(dir, "team.yaml"), base, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mine := []byte(`include: [team.yaml]
+mode: union
+clients:
+  - name: cursor
+    extends: json-perms
+    allow_path: /tmp/my-cursor.json
+  - name: staging
+    extends: local
+    allow_path: /tmp/staging.json
+`)
+	cfgPath := filepath.Join(dir, "syncd.yaml")
+	if err := os.WriteFile(cfgPath, mine, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Mode != "union" || cfg.Source != "claude" {
+		t.Fatalf("scalars not merged: mode=%s source=%s", cfg.Mode, cfg.Source)
+	}
+	if len(cfg.Clients) != 3 {
+		t.Fatalf("expected 3 clients, got %d", len(cfg.Clients))
+	}
+	if cfg.Clients[1].Name != "cursor" || cfg.Clients[1].AllowPath != "/tmp/my-cursor.json" {
+		t.Fatalf("cursor should be replaced by the including file: %+v", cfg.Clients[1])
+	}
+	staging := cfg.Clients[2]
+	if staging.Format != "json-object" || staging.AllowKey != "permissions.allow" || !staging.MissingOK || staging.Direction != DirectionWrite {
+		t.Fatalf("nested template not applied: %+v", staging)
+	}
+
+	cycle := []byte("include: [syncd.yaml]\n")
+	if err := os.WriteFile(filepath.Join(dir, "team.yaml"), cycle, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+
+	loop := []byte(`templates:
+  - name: a
+    extends: b
+  - name: b
+    extends: a
+clients:
+  - name: x
+    extends: a
+`)
+	if err := os.WriteFile(cfgPath, loop, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "template cycle") {
+		t.Fatalf("expected template cycle error, got %v", err)
+	}
+}
+
+func TestLoadLockSettings(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	cfgPath := filepath.Join(dir, "syncd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("clients:\n  - preset: claude\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StateDir != filepath.Join(dir, "state", "syncd") || cfg.LockTimeout != DefaultLockTimeout {
+		t.Fatalf("unexpected defaults: %q %s", cfg.StateDir, cfg.LockTimeout)
+	}
+	if cfg.AuditLog != filepath.Join(cfg.StateDir, "audit.jsonl") {
+		t.Fatalf("unexpected audit log: %q", cfg.AuditLog)
+	}
+	if cfg.StatusFile != filepath.Join(cfg.StateDir, "status.json") {
+		t.Fatalf("unexpected status file: %q", cfg.StatusFile)
+	}
+
+	input := "state_dir: $SYNCD_TEST_STATE/locks\nlock_timeout: 2500ms\naudit_log: off\nstatus_file: off\nclients:\n  - preset: claude\n"
+	t.Setenv("SYNCD_TEST_STATE", dir)
+	if err := os.WriteFile(cfgPath, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StateDir != filepath.Join(dir, "locks") || cfg.LockTimeout != 2500*time.Millisecond || cfg.AuditLog != "" || cfg.StatusFile != "" {
+		t.Fatalf("unexpected settings: %q %s", cfg.StateDir, cfg.LockTimeout)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "syncd.yaml")
+	input := []byte(`clients:
+  - name: test
+    format: json-object
+    allow_path: /tmp/a.json
+    alow_key: permissions.allow
+groups:
+  - name: mcp
+    modes: union
+`)
+	if err := os.WriteFile(cfgPath, input, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(cfgPath)
+	if err == nil {
+		t.Fatal("expected unknown fields to fail")
+	}
+	for _, want := range []string{
+		cfgPath + `:5:5: unknown field "alow_key" (did you mean "allow_key"?)`,
+		cfgPath + `:8:5: unknown field "modes" (did you mean "mode"?)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not contain %q", err, want)
+		}
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("version: 99\nclients:\n  - preset: claude\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+
+	t.Setenv("SYNCD_UNSET_VAR", "")
+	if err := os.WriteFile(cfgPath, []byte("clients:\n  - preset: claude\n    allow_path: $SYNCD_UNSET_VAR/settings.json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "client claude allow_path: $SYNCD_UNSET_VAR/settings.json: $SYNCD_UNSET_VAR not set") {
+		t.Fatalf("expected unset variable error, got %v", err)
+	}
+}
+
+func TestJSONSchemaUpToDate(t *testing.T) {
+	want, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join("..", "..", "syncd.schema.json"))
+	if err != nil {
+		t.Fatalf("read published schema: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatal("syncd.schema.json is stale; run `go run ./cmd/syncd schema -o syncd.schema.json`")
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile parses path and the files it includes. Includes are resolved
+// relative to the including file, may be globs, and are merged in order with
+// the including file applied last. stack holds the absolute paths currently
+// being loaded so include cycles are reported instead of recursing forever.
+func loadFile(path string, home string, stack []string) (Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("resolve %s: %w", path, err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return Config{}, fmt.Errorf("include cycle: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config: %w", err)
+	}
+	cfg, err := parse(b, path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var merged Config
+	for _, inc := range cfg.Include {
+		paths, err := includePaths(inc, filepath.Dir(path), home)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: include %s: %w", path, inc, err)
+		}
+		for _, p := range paths {
+			sub, err := loadFile(p, home, append(stack, abs))
+			if err != nil {
+				return Config{}, fmt.Errorf("%s: include %s: %w", path, inc, err)
+			}
+			merged = mergeConfig(merged, sub)
+		}
+	}
+	cfg.Include = nil
+	return mergeConfig(merged, cfg), nil
+}
+
+// parse decodes one config file strictly: the version is checked and
+// migrated, and unknown keys are rejected with their location.
+func parse(b []byte, path string) (Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return Config{}, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	var cfg Config
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+	root := doc.Content[0]
+	if err := migrate(root, path); err != nil {
+		return Config{}, err
+	}
+	if err := checkFields(root, reflect.TypeOf(cfg), path); err != nil {
+		return Config{}, err
+	}
+	if err := root.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	cfg.Version = CurrentVersion
+	recordPositions(root, &cfg, path)
+	return cfg, nil
+}
+
+func includePaths(inc string, dir string, home string) ([]string, error) {
+	p, err := expandPath(inc, home)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	if !strings.ContainsAny(p, "*?[") {
+		return []string{p}, nil
+	}
+	matches, err := filepath.Glob(p)
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// mergeGuardrails lets over set the action and builtin, and appends its
+// patterns and exemptions.
+func mergeGuardrails(base Guardrails, over Guardrails) Guardrails {
+	out := base
+	if over.Action != "" {
+		out.Action = over.Action
+	}
+	if over.Builtin != nil {
+		out.Builtin = over.Builtin
+	}
+	out.Patterns = append(append([]string{}, base.Patterns...), over.Patterns...)
+	out.Exempt = append(append([]string{}, base.Exempt...), over.Exempt...)
+	if len(over.Pos) > 0 {
+		out.Pos = over.Pos
+	}
+	return out
+}
+
+// mergeConfig applies over on top of base: scalars set in over win, clients,
+// templates and groups with the same name are replaced, and workspaces are
+// appended.
+func mergeConfig(base Config, over Config) Config {
+	out := base
+	if over.Version != 0 {
+		out.Version = over.Version
+	}
+	if over.Mode != "" {
+		out.Mode = over.Mode
+	}
+	if over.Source != "" {
+		out.Source = over.Source
+	}
+	if over.Sort != nil {
+		out.Sort = over.Sort
+	}
+	if over.Dedupe != nil {
+		out.Dedupe = over.Dedupe
+	}
+	if over.StateDir != "" {
+		out.StateDir = over.StateDir
+	}
+	if over.LockTimeout != 0 {
+		out.LockTimeout = over.LockTimeout
+	}
+	if over.AuditLog != "" {
+		out.AuditLog = over.AuditLog
+	}
+	if over.ControlSocket != "" {
+		out.ControlSocket = over.ControlSocket
+	}
+	if over.StatusFile != "" {
+		out.StatusFile = over.StatusFile
+	}
+	out.Guardrails = mergeGuardrails(base.Guardrails, over.Guardrails)
+	if len(base.Pos) > 0 || len(over.Pos) > 0 {
+		out.Pos = Positions{}
+		for k, v := range base.Pos {
+			out.Pos[k] = v
+		}
+		for k, v := range over.Pos {
+			out.Pos[k] = v
+		}
+	}
+	out.Clients = mergeClients(base.Clients, over.Clients)
+	out.Templates = mergeClients(base.Templates, over.Templates)
+	out.Groups = mergeGroups(base.Groups, over.Groups)
+	out.Workspaces = append(append([]Workspace{}, base.Workspaces...), over.Workspaces...)
+	return out
+}
+
+func clientKey(c Client) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Preset
+}
+
+func mergeClients(base []Client, over []Client) []Client {
+	if len(base) == 0 && len(over) == 0 {
+		return nil
+	}
+	out := append([]Client{}, base...)
+	for _, c := range over {
+		replaced := false
+		if key := clientKey(c); key != "" {
+			for i := range base {
+				if clientKey(out[i]) == key {
+					out[i] = c
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func mergeGroups(base []Group, over []Group) []Group {
+	if len(base) == 0 && len(over) == 0 {
+		return nil
+	}
+	out := append([]Group{}, base...)
+	for _, g := range over {
+		merged := false
+		for i := range base {
+			if g.Name == "" || out[i].Name != g.Name {
+				continue
+			}
+			if g.Mode != "" {
+				out[i].Mode = g.Mode
+			}
+			if g.Source != "" {
+				out[i].Source = g.Source
+			}
+			if g.Sort != nil {
+				out[i].Sort = g.Sort
+			}
+			if g.Dedupe != nil {
+				out[i].Dedupe = g.Dedupe
+			}
+			out[i].Clients = mergeClients(out[i].Clients, g.Clients)
+			merged = true
+			break
+		}
+		if !merged {
+			out = append(out, g)
+		}
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// xdgDefaults are the XDG base directories relative to home, used when the
+// variable is unset so "$XDG_CONFIG_HOME/..." works on every system.
+var xdgDefaults = map[string]string{
+	"XDG_CONFIG_HOME": ".config",
+	"XDG_DATA_HOME":   ".local/share",
+	"XDG_STATE_HOME":  ".local/state",
+	"XDG_CACHE_HOME":  ".cache",
+}
+
+var windowsVarRe = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// ExpandPath expands a leading ~ or ~user, $VAR and ${VAR} references, and
+// %VAR% references. Unset $VAR references expand to the empty string, except
+// XDG base directories which fall back to their defaults under home; unset
+// %VAR% references are left as written.
+func ExpandPath(path string, home string) string {
+	if path == "" {
+		return path
+	}
+	path = windowsVarRe.ReplaceAllStringFunc(path, func(m string) string {
+		if v, ok := os.LookupEnv(m[1 : len(m)-1]); ok {
+			return v
+		}
+		return m
+	})
+	path = os.Expand(path, func(name string) string {
+		v, _ := lookupVar(name, home)
+		return v
+	})
+	return expandTilde(path, home)
+}
+
+// expandPath is ExpandPath for configured paths: an unset or empty $VAR
+// reference is an error instead of silently expanding to nothing.
+func expandPath(path string, home string) (string, error) {
+	var unset []string
+	os.Expand(path, func(name string) string {
+		if _, ok := lookupVar(name, home); !ok {
+			unset = append(unset, "$"+name)
+		}
+		return ""
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("%s: %s not set", path, strings.Join(unset, ", "))
+	}
+	return ExpandPath(path, home), nil
+}
+
+func lookupVar(name string, home string) (string, bool) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return v, true
+	}
+	if rel, ok := xdgDefaults[name]; ok {
+		return filepath.Join(home, filepath.FromSlash(rel)), true
+	}
+	if name == "HOME" {
+		return home, true
+	}
+	return "", false
+}
+
+func expandTilde(path string, home string) string {
+	if path == "~" {
+		return home
+	}
+	if strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		return home + path[1:]
+	}
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	name, rest, _ := strings.Cut(path[1:], "/")
+	u, err := user.Lookup(name)
+	if err != nil {
+		return path
+	}
+	if rest == "" {
+		return u.HomeDir
+	}
+	return filepath.Join(u.HomeDir, rest)
+}
+
+// SearchPaths lists where a config is looked for when none is given:
+// $SYNCD_CONFIG, $XDG_CONFIG_HOME/syncd/syncd.yaml, ~/.config/syncd/syncd.yaml
+// and syncd.yaml in the current directory.
+func SearchPaths() []string {
+	var paths []string
+	if p := os.Getenv("SYNCD_CONFIG"); p != "" {
+		paths = append(paths, p)
+	}
+	home, err := os.UserHomeDir()
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "syncd", "syncd.yaml"))
+	}
+	if err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "syncd", "syncd.yaml"))
+	}
+	return append(paths, "syncd.yaml")
+}
+
+// DefaultPath is where new configs are written: the first user-level entry of
+// SearchPaths.
+func DefaultPath() string {
+	return SearchPaths()[0]
+}
+
+// Find returns the first existing config in SearchPaths. $SYNCD_CONFIG is used
+// as given even when the file does not exist, so the error names it.
+func Find() (string, error) {
+	if p := os.Getenv("SYNCD_CONFIG"); p != "" {
+		return p, nil
+	}
+	paths := SearchPaths()
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no config found (searched %s)", strings.Join(paths, ", "))
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return ""
+	}
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Positions records where a YAML mapping and its keys appear. The empty key
+// is the mapping itself; field keys point at the field's value.
+type Positions map[string]Position
+
+// Of returns the position of field, or of the mapping when the field is not
+// set in the file.
+func (p Positions) Of(field string) Position {
+	if pos, ok := p[field]; ok {
+		return pos
+	}
+	return p[""]
+}
+
+func nodePositions(node *yaml.Node, file string) Positions {
+	out := Positions{"": {File: file, Line: node.Line, Column: node.Column}}
+	if node.Kind != yaml.MappingNode {
+		return out
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		v := node.Content[i+1]
+		out[node.Content[i].Value] = Position{File: file, Line: v.Line, Column: v.Column}
+	}
+	return out
+}
+
+func sequenceItems(node *yaml.Node, key string) []*yaml.Node {
+	v := mappingValue(node, key)
+	if v == nil || v.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return v.Content
+}
+
+// recordPositions fills the Pos fields of cfg from the document it was
+// decoded from.
+func recordPositions(root *yaml.Node, cfg *Config, file string) {
+	cfg.Pos = nodePositions(root, file)
+	recordClients(sequenceItems(root, "clients"), cfg.Clients, file)
+	recordClients(sequenceItems(root, "templates"), cfg.Templates, file)
+	for i, item := range sequenceItems(root, "groups") {
+		if i >= len(cfg.Groups) {
+			break
+		}
+		cfg.Groups[i].Pos = nodePositions(item, file)
+		recordClients(sequenceItems(item, "clients"), cfg.Groups[i].Clients, file)
+	}
+	if v := mappingValue(root, "guardrails"); v != nil {
+		cfg.Guardrails.Pos = nodePositions(v, file)
+	}
+	for i, item := range sequenceItems(root, "workspaces") {
+		if i >= len(cfg.Workspaces) {
+			break
+		}
+		cfg.Workspaces[i].Pos = nodePositions(item, file)
+	}
+}
+
+func recordClients(items []*yaml.Node, clients []Client, file string) {
+	for i, item := range items {
+		if i >= len(clients) {
+			break
+		}
+		clients[i].Pos = nodePositions(item, file)
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+)
+
+// Preset describes where a known tool keeps its allow/deny lists. Paths are
+// keyed by GOOS, with "" as the fallback for any other OS.
+type Preset struct {
+	Name        string
+	Description string
+	Format      string
+	Paths       map[string]string
+	AllowKey    string
+	DenyKey     string
+	Accepts     []string
+}
+
+var presets = []Preset{
+	{
+		Name:        "claude",
+		Description: "Claude Code user settings",
+		Format:      "json-object",
+		Paths:       map[string]string{"": "~/.claude/settings.json"},
+		AllowKey:    "permissions.allow",
+		DenyKey:     "permissions.deny",
+	},
+	{
+		Name:        "cursor",
+		Description: "Cursor CLI config",
+		Format:      "json-object",
+		Paths:       map[string]string{"": "~/.cursor/cli-config.json"},
+		AllowKey:    "permissions.allow",
+		DenyKey:     "permissions.deny",
+	},
+	{
+		Name:        "codex",
+		Description: "Codex CLI managed prefix rules",
+		Format:      "codex-rules",
+		Paths:       map[string]string{"": "~/.codex/rules/default.rules"},
+		Accepts:     []string{"command"},
+	},
+	{
+		Name:        "kilocode",
+		Description: "Kilo Code CLI auto-approval",
+		Format:      "json-object",
+		Paths:       map[string]string{"": "~/.kilocode/config.json"},
+		AllowKey:    "autoApproval.execute.allowed",
+		DenyKey:     "autoApproval.execute.denied",
+		Accepts:     []string{"command"},
+	},
+	{
+		Name:        "gemini",
+		Description: "Gemini CLI core tools",
+		Format:      "json-object",
+		Paths:       map[string]string{"": "~/.gemini/settings.json"},
+		AllowKey:    "coreTools",
+		DenyKey:     "excludeTools",
+		Accepts:     []string{"command", "file", "web", "other"},
+	},
+	{
+		Name:        "gemini-mcp",
+		Description: "Gemini CLI MCP servers",
+		Format:      "json-object",
+		Paths:       map[string]string{"": "~/.gemini/settings.json"},
+		AllowKey:    "allowMCPServers",
+		DenyKey:     "excludeMCPServers",
+		Accepts:     []string{"mcp"},
+	},
+	{
+		Name:        "qwen",
+		Description: "Qwen Code MCP servers",
+		Format:      "json-object",
+		Paths:       map[string]string{"": "~/.qwen/settings.json"},
+		AllowKey:    "mcp.allowed",
+		DenyKey:     "mcp.excluded",
+		Accepts:     []string{"mcp"},
+	},
+	{
+		Name:        "roo-cline",
+		Description: "Roo/Cline commands in Cursor settings",
+		Format:      "json-object",
+		Paths: map[string]string{
+			"darwin":  "~/Library/Application Support/Cursor/User/settings.json",
+			"windows": "%APPDATA%/Cursor/User/settings.json",
+			"":        "$XDG_CONFIG_HOME/Cursor/User/settings.json",
+		},
+		AllowKey: "roo-cline.allowedCommands",
+		DenyKey:  "roo-cline.deniedCommands",
+		Accepts:  []string{"command"},
+	},
+	{
+		Name:        "vscode-copilot",
+		Description: "VS Code Copilot terminal auto-approve",
+		Format:      "json-bool-map",
+		Paths: map[string]string{
+			"darwin":  "~/Library/Application Support/Code/User/settings.json",
+			"windows": "%APPDATA%/Code/User/settings.json",
+			"":        "$XDG_CONFIG_HOME/Code/User/settings.json",
+		},
+		AllowKey: "chat.tools.terminal.autoApprove",
+		Accepts:  []string{"command"},
+	},
+}
+
+func Presets() []Preset {
+	out := make([]Preset, len(presets))
+	copy(out, presets)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func LookupPreset(name string) (Preset, bool) {
+	for _, p := range presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+func (p Preset) Path(goos string) string {
+	if path, ok := p.Paths[goos]; ok {
+		return path
+	}
+	return p.Paths[""]
+}
+
+// Client returns a client configured entirely from the preset, with paths
+// for goos expanded against home.
+func (p Preset) Client(goos string, home string) Client {
+	c := Client{Preset: p.Name}
+	_ = applyPreset(&c, goos)
+	c.AllowPath = ExpandPath(c.AllowPath, home)
+	c.DenyPath = ExpandPath(c.DenyPath, home)
+	return c
+}
+
+// applyPreset fills fields the client left empty from its preset.
+func applyPreset(c *Client, goos string) error {
+	if c.Preset == "" {
+		return nil
+	}
+	p, ok := LookupPreset(c.Preset)
+	if !ok {
+		return fmt.Errorf("client %s: unknown preset %q", c.Name, c.Preset)
+	}
+	if c.Name == "" {
+		c.Name = p.Name
+	}
+	if c.Format == "" {
+		c.Format = p.Format
+	}
+	if c.AllowPath == "" && c.DenyPath == "" {
+		c.AllowPath = p.Path(goos)
+	}
+	if c.AllowKey == "" {
+		c.AllowKey = p.AllowKey
+	}
+	if c.DenyKey == "" {
+		c.DenyKey = p.DenyKey
+	}
+	if len(c.Accepts) == 0 && len(p.Accepts) > 0 {
+		c.Accepts = append([]string(nil), p.Accepts...)
+	}
+	return nil
+}
+
+func applyPresets(clients []Client) error {
+	for i := range clients {
+		if err := applyPreset(&clients[i], runtime.GOOS); err != nil {
+			return err
+		}
+	}
+	return nil
+}
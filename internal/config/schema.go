@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/guard"
+)
+
+var formatNames = []string{
+	"newline", "lines", "txt",
+	"json", "json-array", "jsonarray",
+	"json-object", "json-bool-map", "codex-rules",
+}
+
+// schemaHints adds descriptions and enums to properties by yaml field name.
+var schemaHints = map[string]map[string]any{
+	"version":        {"description": "Config schema version.", "enum": []int{CurrentVersion}},
+	"include":        {"description": "Other config files merged before this one, relative to this file. Globs are allowed."},
+	"templates":      {"description": "Named client templates that clients can extend."},
+	"mode":           {"description": "How client lists are merged.", "enum": []string{"union", "authoritative"}},
+	"source":         {"description": "Client whose lists are used in authoritative mode."},
+	"sort":           {"description": "Sort merged lists (default true)."},
+	"dedupe":         {"description": "Drop command entries already granted by a broader entry in the same list, e.g. Bash(git status) under Bash(git:*) (default false). On a client, false opts it out."},
+	"clients":        {"description": "Tools whose allow/deny lists are synced."},
+	"groups":         {"description": "Named policy groups, each merged independently."},
+	"workspaces":     {"description": "Directories scanned for project-level settings files."},
+	"name":           {"description": "Unique name."},
+	"preset":         {"description": "Built-in tool preset supplying format, paths and keys.", "enum": presetNames()},
+	"extends":        {"description": "Template whose fields this client inherits."},
+	"allow_path":     {"description": "File holding the allow list."},
+	"deny_path":      {"description": "File holding the deny list."},
+	"format":         {"description": "File format.", "enum": formatNames},
+	"allow_key":      {"description": "Dot-path of the allow list in a JSON document."},
+	"deny_key":       {"description": "Dot-path of the deny list in a JSON document."},
+	"missing_ok":     {"description": "Treat a missing file as empty."},
+	"direction":      {"description": "Whether the client is read, written or both.", "enum": []string{DirectionRead, DirectionWrite, DirectionBoth}},
+	"accepts":        {"description": "Entry categories written to this client (default all)."},
+	"roots":          {"description": "Directories to scan for projects."},
+	"max_depth":      {"description": "Directory levels below each root to scan (default 3)."},
+	"files":          {"description": "Settings files to look for in each project, relative to the project."},
+	"policy":         {"description": "Policy applied to project files.", "enum": []string{WorkspacePolicyUser, WorkspacePolicyProject}},
+	"group":          {"description": "Group whose merged policy is applied when policy is user."},
+	"allow":          {"description": "Project allow list when policy is project."},
+	"deny":           {"description": "Project deny list when policy is project."},
+	"state_dir":      {"description": "Directory for lock files (default $XDG_STATE_HOME/syncd)."},
+	"audit_log":      {"description": "JSON Lines file recording every added or removed entry (default state_dir/audit.jsonl); off disables it."},
+	"control_socket": {"description": "Unix socket of the daemon's control API (default under state_dir/control); off disables it."},
+	"status_file":    {"description": "JSON file the daemon rewrites after every run with its state and last run (default state_dir/status.json); off disables it."},
+	"guardrails":     {"description": "Rules that stop dangerous allow entries from being synced."},
+	"action":         {"description": "What happens to a blocked allow entry: kept only by the clients that have it (block), moved to deny (deny), or the run fails (abort). Default block.", "enum": guard.Actions},
+	"builtin":        {"description": "Apply the built-in rules for any-command, recursive rm, sudo and pipe-to-shell entries (default true)."},
+	"patterns":       {"description": "Extra patterns matched against allow entries and the commands they grant; * matches any text."},
+	"exempt":         {"description": "Allow entries that are never blocked."},
+	"lock_timeout":   {"description": "How long to wait for a file lock held by another syncd, as a duration like 10s (default 10s)."},
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for _, p := range Presets() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// JSONSchema returns a JSON Schema for syncd.yaml generated from the Config
+// type, for use by editors.
+func JSONSchema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(Config{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "syncd configuration"
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return map[string]any{"type": "string", "pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := yamlName(f)
+			if name == "" {
+				continue
+			}
+			prop := schemaFor(f.Type)
+			for k, v := range schemaHints[name] {
+				if k == "enum" && prop["type"] == "array" {
+					continue
+				}
+				prop[k] = v
+			}
+			if name == "accepts" {
+				prop["items"] = map[string]any{"type": "string", "enum": entry.Categories}
+			}
+			props[name] = prop
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
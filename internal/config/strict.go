@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version this build writes and reads.
+// Files without a version are treated as the current version.
+const CurrentVersion = 1
+
+// migrations upgrade a parsed document from version `from` to from+1. They run
+// in order before decoding so older files keep loading after schema changes.
+var migrations = map[int]func(root *yaml.Node) error{}
+
+func migrate(root *yaml.Node, file string) error {
+	version := CurrentVersion
+	if v := mappingValue(root, "version"); v != nil {
+		if err := v.Decode(&version); err != nil {
+			return fmt.Errorf("%s:%d:%d: version must be an integer", file, v.Line, v.Column)
+		}
+	}
+	if version > CurrentVersion {
+		return fmt.Errorf("%s: config version %d is newer than supported version %d; upgrade syncd", file, version, CurrentVersion)
+	}
+	if version < 1 {
+		return fmt.Errorf("%s: invalid config version %d", file, version)
+	}
+	for v := version; v < CurrentVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return fmt.Errorf("%s: no migration from config version %d", file, v)
+		}
+		if err := m(root); err != nil {
+			return fmt.Errorf("%s: migrate from version %d: %w", file, v, err)
+		}
+	}
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// checkFields reports every mapping key that does not match a yaml field of t,
+// with its file:line:column and the closest known field when there is one.
+func checkFields(node *yaml.Node, t reflect.Type, file string) error {
+	var errs []error
+	walkFields(node, t, file, &errs)
+	return errors.Join(errs...)
+}
+
+func walkFields(node *yaml.Node, t reflect.Type, file string, errs *[]error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("%s:%d:%d: unknown field %q", file, key.Line, key.Column, key.Value)
+				if hint := closestField(key.Value, fields); hint != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", hint)
+				}
+				*errs = append(*errs, errors.New(msg))
+				continue
+			}
+			walkFields(value, field.Type, file, errs)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, item := range node.Content {
+			walkFields(item, t.Elem(), file, errs)
+		}
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	out := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+		out[name] = f
+	}
+	return out
+}
+
+func yamlName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+func closestField(key string, fields map[string]reflect.StructField) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && best != "" && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveTemplates replaces every client's extends with the fields of the named
+// template. Templates may extend other templates.
+func resolveTemplates(cfg *Config) error {
+	templates := make(map[string]Client, len(cfg.Templates))
+	for _, t := range cfg.Templates {
+		if t.Name == "" {
+			return fmt.Errorf("template without name")
+		}
+		templates[t.Name] = t
+	}
+	for i := range cfg.Clients {
+		c, err := extendClient(cfg.Clients[i], templates, nil)
+		if err != nil {
+			return fmt.Errorf("client %s: %w", clientKey(cfg.Clients[i]), err)
+		}
+		cfg.Clients[i] = c
+	}
+	for gi := range cfg.Groups {
+		clients := cfg.Groups[gi].Clients
+		for i := range clients {
+			c, err := extendClient(clients[i], templates, nil)
+			if err != nil {
+				return fmt.Errorf("group %s: client %s: %w", cfg.Groups[gi].Name, clientKey(clients[i]), err)
+			}
+			clients[i] = c
+		}
+	}
+	return nil
+}
+
+func extendClient(c Client, templates map[string]Client, chain []string) (Client, error) {
+	if c.Extends == "" {
+		return c, nil
+	}
+	for _, name := range chain {
+		if name == c.Extends {
+			return Client{}, fmt.Errorf("template cycle: %s", strings.Join(append(chain, c.Extends), " -> "))
+		}
+	}
+	base, ok := templates[c.Extends]
+	if !ok {
+		return Client{}, fmt.Errorf("unknown template %q", c.Extends)
+	}
+	base, err := extendClient(base, templates, append(chain, c.Extends))
+	if err != nil {
+		return Client{}, err
+	}
+	out := mergeClientFields(base, c)
+	out.Name = c.Name
+	out.Extends = ""
+	out.Pos = c.Pos
+	return out, nil
+}
+
+// mergeClientFields returns base with every field set on over applied on top.
+// missing_ok is true if either sets it.
+func mergeClientFields(base Client, over Client) Client {
+	out := base
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&out.Name, over.Name)
+	set(&out.Preset, over.Preset)
+	set(&out.AllowPath, over.AllowPath)
+	set(&out.DenyPath, over.DenyPath)
+	set(&out.Format, over.Format)
+	set(&out.AllowKey, over.AllowKey)
+	set(&out.DenyKey, over.DenyKey)
+	set(&out.Direction, over.Direction)
+	if len(over.Accepts) > 0 {
+		out.Accepts = over.Accepts
+	}
+	if over.Dedupe != nil {
+		out.Dedupe = over.Dedupe
+	}
+	out.MissingOK = base.MissingOK || over.MissingOK
+	return out
+}
@@ -0,0 +1,318 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/audit"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+type Status struct {
+	Config      string         `json:"config"`
+	Paused      bool           `json:"paused"`
+	DryRun      bool           `json:"dry_run,omitempty"`
+	Interval    string         `json:"interval"`
+	LastRun     *RunStatus     `json:"last_run,omitempty"`
+	LastSuccess *time.Time     `json:"last_success,omitempty"`
+	Clients     []ClientStatus `json:"clients"`
+}
+
+type RunStatus struct {
+	RunID    string    `json:"run_id,omitempty"`
+	Trigger  string    `json:"trigger"`
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	Changes  int       `json:"changes"`
+	Retries  int       `json:"retries,omitempty"`
+	Blocked  int       `json:"blocked,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Client   string    `json:"error_client,omitempty"`
+}
+
+// ClientStatus describes a client as of the last successful run: the size of
+// the lists it holds and how many entries that run added and removed.
+type ClientStatus struct {
+	Group     string `json:"group"`
+	Client    string `json:"client"`
+	Direction string `json:"direction"`
+	Allow     int    `json:"allow"`
+	Deny      int    `json:"deny"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+}
+
+type GroupPolicy struct {
+	Name  string   `json:"name"`
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+type PolicyResponse struct {
+	RunID  string        `json:"run_id,omitempty"`
+	Groups []GroupPolicy `json:"groups"`
+}
+
+// Handler serves the control API:
+//
+//	GET  /v1/status  daemon state, last run and per-client status
+//	GET  /v1/policy  merged policy of each group from the last successful run
+//	POST /v1/sync    run a sync now (also while paused) and return its status
+//	POST /v1/pause   stop interval runs
+//	POST /v1/resume  restart interval runs
+//	POST /v1/reload  load the config file again
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("GET /v1/policy", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, d.Policy())
+	})
+	mux.HandleFunc("POST /v1/sync", func(w http.ResponseWriter, _ *http.Request) {
+		out := d.SyncNow(TriggerAPI)
+		code := http.StatusOK
+		if out.Err != nil {
+			code = http.StatusInternalServerError
+		}
+		writeJSON(w, code, runStatus(out))
+	})
+	mux.HandleFunc("POST /v1/pause", func(w http.ResponseWriter, _ *http.Request) {
+		d.Pause()
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /v1/resume", func(w http.ResponseWriter, _ *http.Request) {
+		d.Resume()
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /v1/reload", func(w http.ResponseWriter, _ *http.Request) {
+		if err := d.Reload(); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	return mux
+}
+
+func (d *Daemon) Status() Status {
+	last, good := d.Last()
+	st := Status{
+		Config:   d.ConfigPath,
+		Paused:   d.Paused(),
+		DryRun:   d.DryRun,
+		Interval: d.Interval.String(),
+		Clients:  []ClientStatus{},
+	}
+	if last != nil {
+		rs := runStatus(*last)
+		st.LastRun = &rs
+	}
+	if good == nil {
+		return st
+	}
+	st.LastSuccess = &good.Start
+	type key struct{ group, client string }
+	added := map[key]int{}
+	removed := map[key]int{}
+	for _, c := range good.Result.Changes {
+		if c.Action == audit.Added {
+			added[key{c.Group, c.Client}]++
+		} else {
+			removed[key{c.Group, c.Client}]++
+		}
+	}
+	for _, group := range good.Result.Groups {
+		for _, snap := range group.Clients {
+			policy := snap.Policy
+			if snap.Client.Writes() {
+				policy = snap.Target
+			}
+			k := key{group.Name, snap.Client.Name}
+			st.Clients = append(st.Clients, ClientStatus{
+				Group:     group.Name,
+				Client:    snap.Client.Name,
+				Direction: direction(snap.Client.Reads(), snap.Client.Writes()),
+				Allow:     len(policy.Allow),
+				Deny:      len(policy.Deny),
+				Added:     added[k],
+				Removed:   removed[k],
+			})
+		}
+	}
+	return st
+}
+
+// WriteStatus writes Status as JSON to path, replacing it atomically so
+// readers never see a partial file.
+func (d *Daemon) WriteStatus(path string) error {
+	data, err := json.MarshalIndent(d.Status(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".status-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadStatus reads a status file written by WriteStatus.
+func ReadStatus(path string) (Status, error) {
+	var st Status
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("%s: %w", path, err)
+	}
+	return st, nil
+}
+
+func (d *Daemon) Policy() PolicyResponse {
+	resp := PolicyResponse{Groups: []GroupPolicy{}}
+	_, good := d.Last()
+	if good == nil {
+		return resp
+	}
+	resp.RunID = good.Result.RunID
+	for _, group := range good.Result.Groups {
+		resp.Groups = append(resp.Groups, GroupPolicy{
+			Name:  group.Name,
+			Allow: nonNil(group.Policy.Allow),
+			Deny:  nonNil(group.Policy.Deny),
+		})
+	}
+	return resp
+}
+
+func runStatus(out Outcome) RunStatus {
+	rs := RunStatus{
+		RunID:    out.Result.RunID,
+		Trigger:  out.Trigger,
+		Start:    out.Start,
+		Duration: out.Duration.String(),
+		Changes:  len(out.Result.Changes),
+		Retries:  out.Result.Retries,
+		Blocked:  len(out.Result.Blocked),
+	}
+	if out.Err != nil {
+		rs.Error = out.Err.Error()
+		var guardErr *sync.GuardError
+		if errors.As(out.Err, &guardErr) {
+			rs.Blocked = len(guardErr.Blocked)
+		}
+		var clientErr *sync.ClientError
+		if errors.As(out.Err, &clientErr) {
+			rs.Client = clientErr.Client
+		}
+	}
+	return rs
+}
+
+func direction(reads bool, writes bool) string {
+	switch {
+	case reads && writes:
+		return "both"
+	case reads:
+		return "read"
+	default:
+		return "write"
+	}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// ServeControl listens on the Unix socket at path, replacing a stale socket
+// file, and serves the control API in the background until the returned
+// function is called. The socket is only accessible to the current user.
+func (d *Daemon) ServeControl(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	srv := &http.Server{Handler: d.Handler()}
+	go srv.Serve(ln)
+	return func() {
+		srv.Close()
+		os.Remove(path)
+	}, nil
+}
+
+// Call sends a control request to the daemon listening on the socket at path
+// and returns the response body. Error responses are returned as errors.
+func Call(path string, method string, endpoint string) ([]byte, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	req, err := http.NewRequest(method, "http://syncd"+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return body, errors.New(e.Error)
+		}
+		return body, fmt.Errorf("%s %s: %s", method, endpoint, resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,180 @@
+// Package daemon runs syncs on an interval and serves the local control API
+// that can trigger, pause, resume and reload it.
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	stdsync "sync"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/lock"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+// Outcome is the result of one sync run.
+type Outcome struct {
+	Result   sync.Result
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+	Trigger  string
+}
+
+const (
+	TriggerInterval = "interval"
+	TriggerAPI      = "api"
+)
+
+type Daemon struct {
+	ConfigPath string
+	Interval   time.Duration
+	DryRun     bool
+	// AfterRun, when set, is called after every run, in the running goroutine.
+	AfterRun func(Outcome)
+	// Logger receives run summaries and control actions.
+	Logger *slog.Logger
+
+	runMu stdsync.Mutex
+
+	mu       stdsync.Mutex
+	cfg      config.Config
+	paused   bool
+	running  time.Time
+	last     *Outcome
+	lastGood *Outcome
+}
+
+func New(configPath string, cfg config.Config, interval time.Duration) *Daemon {
+	return &Daemon{ConfigPath: configPath, Interval: interval, Logger: slog.Default(), cfg: cfg}
+}
+
+// Options returns the sync options for a run of cfg. Dry runs only read, so
+// they take no file locks and create nothing in the state directory.
+func Options(cfg config.Config, dryRun bool) sync.Options {
+	opts := sync.Options{
+		DryRun:      dryRun,
+		LockTimeout: cfg.LockTimeout,
+		AuditLog:    cfg.AuditLog,
+	}
+	if !dryRun {
+		opts.LockDir = filepath.Join(cfg.StateDir, "locks")
+	}
+	return opts
+}
+
+// SocketPath is the default control socket for the config at configPath.
+func SocketPath(stateDir string, configPath string) string {
+	p := lock.PathFor(filepath.Join(stateDir, "control"), configPath)
+	return p[:len(p)-len(filepath.Ext(p))] + ".sock"
+}
+
+// Loop syncs immediately and then every Interval until ctx is done. Interval
+// runs are skipped while paused.
+func (d *Daemon) Loop(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		if !d.Paused() {
+			d.SyncNow(TriggerInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncNow runs a sync with the current config, waiting for any run already
+// in progress to finish first.
+func (d *Daemon) SyncNow(trigger string) Outcome {
+	d.runMu.Lock()
+	defer d.runMu.Unlock()
+
+	cfg := d.Config()
+	out := Outcome{Start: time.Now(), Trigger: trigger}
+	d.mu.Lock()
+	d.running = out.Start
+	d.mu.Unlock()
+	opts := Options(cfg, d.DryRun)
+	opts.Logger = d.Logger.With("trigger", trigger)
+	out.Result, out.Err = sync.Run(cfg, opts)
+	out.Duration = time.Since(out.Start)
+
+	d.mu.Lock()
+	d.running = time.Time{}
+	d.last = &out
+	if out.Err == nil {
+		d.lastGood = &out
+	}
+	d.mu.Unlock()
+	if cfg.StatusFile != "" {
+		if err := d.WriteStatus(cfg.StatusFile); err != nil {
+			d.Logger.Warn("write status file", "path", cfg.StatusFile, "error", err)
+		}
+	}
+	if d.AfterRun != nil {
+		d.AfterRun(out)
+	}
+	return out
+}
+
+func (d *Daemon) Config() config.Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cfg
+}
+
+// Reload loads ConfigPath again and uses it from the next run. On error the
+// current config is kept.
+func (d *Daemon) Reload() error {
+	cfg, err := config.Load(d.ConfigPath)
+	if err != nil {
+		d.Logger.Error("reload failed, keeping current config", "config", d.ConfigPath, "error", err)
+		return err
+	}
+	d.mu.Lock()
+	d.cfg = cfg
+	d.mu.Unlock()
+	d.Logger.Info("config reloaded", "config", d.ConfigPath)
+	return nil
+}
+
+func (d *Daemon) Pause() {
+	d.mu.Lock()
+	d.paused = true
+	d.mu.Unlock()
+	d.Logger.Info("syncing paused")
+}
+
+func (d *Daemon) Resume() {
+	d.mu.Lock()
+	d.paused = false
+	d.mu.Unlock()
+	d.Logger.Info("syncing resumed")
+}
+
+// RunningSince returns when the run in progress started, or the zero time
+// when idle.
+func (d *Daemon) RunningSince() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.running
+}
+
+func (d *Daemon) Paused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paused
+}
+
+// Last returns the most recent run and the last successful one, either of
+// which is nil before the first such run.
+func (d *Daemon) Last() (last *Outcome, good *Outcome) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.last, d.lastGood
+}
@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/sync"
+)
+
+func TestControlAPI(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
+	if err := os.WriteFile(a, []byte(`{"allow":["ls"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte(`{"allow":["pwd"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(dir, "syncd.yaml")
+	input := "state_dir: " + filepath.Join(dir, "state") + "\nclients:\n" +
+		"  - {name: a, format: json-object, allow_path: " + a + ", allow_key: allow}\n" +
+		"  - {name: b, format: json-object, allow_path: " + b + ", allow_key: allow}\n"
+	if err := os.WriteFile(cfgPath, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(cfgPath, cfg, time.Hour)
+	socket := filepath.Join(dir, "ctl.sock")
+	closeControl, err := d.ServeControl(socket)
+	if err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	defer closeControl()
+
+	call := func(method string, endpoint string, v any) error {
+		body, err := Call(socket, method, endpoint)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, v)
+	}
+
+	var st Status
+	if err := call(http.MethodPost, "/v1/pause", &st); err != nil || !st.Paused {
+		t.Fatalf("pause: %v %+v", err, st)
+	}
+	var run RunStatus
+	if err := call(http.MethodPost, "/v1/sync", &run); err != nil || run.Changes != 2 || run.Trigger != TriggerAPI {
+		t.Fatalf("sync: %v %+v", err, run)
+	}
+	var policy PolicyResponse
+	if err := call(http.MethodGet, "/v1/policy", &policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Groups) != 1 || !reflect.DeepEqual(policy.Groups[0].Allow, []string{"ls", "pwd"}) {
+		t.Fatalf("policy: %+v", policy)
+	}
+	if err := call(http.MethodGet, "/v1/status", &st); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Clients) != 2 || st.Clients[0].Allow != 2 || st.Clients[0].Added != 1 || st.LastRun.RunID != run.RunID {
+		t.Fatalf("status: %+v", st)
+	}
+	saved, err := ReadStatus(cfg.StatusFile)
+	if err != nil || saved.LastRun == nil || saved.LastRun.RunID != run.RunID || len(saved.Clients) != 2 {
+		t.Fatalf("status file: %v %+v", err, saved)
+	}
+	if err := call(http.MethodPost, "/v1/resume", &st); err != nil || st.Paused {
+		t.Fatalf("resume: %v %+v", err, st)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte(input+"bogus: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(http.MethodPost, "/v1/reload", &st); err == nil {
+		t.Fatal("expected reload of an invalid config to fail")
+	}
+	if d.Config().StateDir != cfg.StateDir {
+		t.Fatal("failed reload replaced the config")
+	}
+}
+
+func TestDryRunTakesNoLocks(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.json")
+	if err := os.WriteFile(a, []byte(`{"allow":["ls"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "state")
+	cfg := config.Config{
+		StateDir: state,
+		Clients:  []config.Client{{Name: "a", Format: "json-object", AllowPath: a, AllowKey: "allow"}},
+	}
+	if _, err := sync.Run(cfg, Options(cfg, true)); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Fatalf("dry run created %s: %v", state, err)
+	}
+	if _, err := sync.Run(cfg, Options(cfg, false)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(state, "locks")); err != nil {
+		t.Fatalf("run should lock through %s: %v", state, err)
+	}
+}
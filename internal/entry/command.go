@@ -0,0 +1,128 @@
+package entry
+
+import "strings"
+
+// Cmd is the shell command a command entry grants. With Prefix set the entry
+// also grants every command that continues Text with more words; an empty
+// Text with Prefix grants every command.
+type Cmd struct {
+	Tool   string
+	Text   string
+	Prefix bool
+}
+
+// ParseCommand returns the command granted by a command entry such as
+// "Bash(git status:*)", "run_shell_command(git)", "Bash" or plain "git
+// status", and false for entries of other categories. Claude's Bash(...) is
+// exact unless it ends in a wildcard; other tools and plain entries match by
+// prefix.
+func ParseCommand(value string) (Cmd, bool) {
+	v := strings.TrimSpace(value)
+	c, known := Classify(v)
+	if c != Command {
+		return Cmd{}, false
+	}
+	if !known {
+		return trimWildcard(Cmd{Text: v, Prefix: true}), true
+	}
+	m := toolCallRe.FindStringSubmatch(v)
+	if m == nil {
+		return Cmd{Tool: v, Prefix: true}, true
+	}
+	cmd := Cmd{Tool: m[1], Text: strings.TrimSpace(m[2]), Prefix: m[1] != "Bash"}
+	return trimWildcard(cmd), true
+}
+
+func trimWildcard(cmd Cmd) Cmd {
+	for _, suffix := range []string{":*", " *", "*"} {
+		if strings.HasSuffix(cmd.Text, suffix) {
+			cmd.Text = strings.TrimSpace(strings.TrimSuffix(cmd.Text, suffix))
+			cmd.Prefix = true
+			break
+		}
+	}
+	return cmd
+}
+
+// Segments splits a command line into the simple commands joined by ;, &&,
+// || and |, each as its words. Quoting is not interpreted.
+func Segments(text string) [][]string {
+	var out [][]string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ';' || r == '&' || r == '|'
+	}) {
+		if words := strings.Fields(part); len(words) > 0 {
+			out = append(out, words)
+		}
+	}
+	return out
+}
+
+// Covers reports whether c grants every command o grants. Entries of
+// different tools never cover each other, since each tool reads only its own
+// syntax.
+func (c Cmd) Covers(o Cmd) bool {
+	if c.Tool != o.Tool {
+		return false
+	}
+	if !c.Prefix {
+		return !o.Prefix && c.Text == o.Text
+	}
+	return c.Text == "" || o.Text == c.Text || strings.HasPrefix(o.Text, c.Text+" ")
+}
+
+// Covers reports whether the command entry broad grants everything the
+// distinct command entry narrow grants, e.g. "Bash(git:*)" covers
+// "Bash(git status)". It is false when either is not a command entry.
+func Covers(broad string, narrow string) bool {
+	if broad == narrow {
+		return false
+	}
+	b, ok := ParseCommand(broad)
+	if !ok {
+		return false
+	}
+	n, ok := ParseCommand(narrow)
+	return ok && b.Covers(n)
+}
+
+// Collapse drops the command entries of a list that another entry of the
+// list covers, keeping order, and maps each dropped entry to the kept entry
+// covering it. Entries isCommand rejects are kept. Of equivalent entries such
+// as "Bash(git:*)" and "Bash(git *)" the first is kept.
+func Collapse(values []string, isCommand func(string) bool) ([]string, map[string]string) {
+	cmds := make([]Cmd, len(values))
+	ok := make([]bool, len(values))
+	for i, v := range values {
+		if isCommand(v) {
+			cmds[i], ok[i] = ParseCommand(v)
+		}
+	}
+	dropped := make([]bool, len(values))
+	for i := range values {
+		for j := range values {
+			if i == j || !ok[i] || !ok[j] || !cmds[j].Covers(cmds[i]) {
+				continue
+			}
+			if !cmds[i].Covers(cmds[j]) || j < i {
+				dropped[i] = true
+				break
+			}
+		}
+	}
+	kept := make([]string, 0, len(values))
+	collapsed := map[string]string{}
+	for i, v := range values {
+		if !dropped[i] {
+			kept = append(kept, v)
+			continue
+		}
+		for j := range values {
+			if !dropped[j] && ok[j] && cmds[j].Covers(cmds[i]) {
+				collapsed[v] = values[j]
+				break
+			}
+		}
+	}
+	return kept, collapsed
+}
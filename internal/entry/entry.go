@@ -0,0 +1,149 @@
+package entry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Category string
+
+const (
+	Command Category = "command"
+	MCP     Category = "mcp"
+	File    Category = "file"
+	Web     Category = "web"
+	Other   Category = "other"
+)
+
+var Categories = []Category{Command, MCP, File, Web, Other}
+
+func ParseCategory(name string) (Category, error) {
+	for _, c := range Categories {
+		if strings.EqualFold(name, string(c)) {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown category %q", name)
+}
+
+var toolCallRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_\-]*)\((.*)\)$`)
+
+// toolCategories maps tool names used by Claude, Cursor, Gemini and Qwen
+// permission entries to the kind of capability they grant.
+var toolCategories = map[string]Category{
+	"Bash":                Command,
+	"Shell":               Command,
+	"ShellTool":           Command,
+	"run_shell_command":   Command,
+	"Read":                File,
+	"Write":               File,
+	"Edit":                File,
+	"MultiEdit":           File,
+	"NotebookRead":        File,
+	"NotebookEdit":        File,
+	"Glob":                File,
+	"Grep":                File,
+	"LS":                  File,
+	"ReadFileTool":        File,
+	"WriteFileTool":       File,
+	"EditTool":            File,
+	"GlobTool":            File,
+	"GrepTool":            File,
+	"LSTool":              File,
+	"ReadManyFilesTool":   File,
+	"read_file":           File,
+	"write_file":          File,
+	"read_many_files":     File,
+	"list_directory":      File,
+	"search_file_content": File,
+	"WebFetch":            Web,
+	"WebSearch":           Web,
+	"WebFetchTool":        Web,
+	"WebSearchTool":       Web,
+	"web_fetch":           Web,
+	"google_web_search":   Web,
+	"Task":                Other,
+	"TodoWrite":           Other,
+	"MemoryTool":          Other,
+	"save_memory":         Other,
+}
+
+// Classify returns the category implied by an entry's syntax. The second
+// result is false for plain entries such as "git status" or "github" whose
+// meaning depends on the tool that stores them.
+func Classify(value string) (Category, bool) {
+	v := strings.TrimSpace(value)
+	if strings.HasPrefix(v, "mcp__") {
+		return MCP, true
+	}
+	if m := toolCallRe.FindStringSubmatch(v); m != nil {
+		if c, ok := toolCategories[m[1]]; ok {
+			return c, true
+		}
+		return Other, true
+	}
+	if c, ok := toolCategories[v]; ok {
+		return c, true
+	}
+	switch {
+	case strings.HasPrefix(v, "http://"), strings.HasPrefix(v, "https://"), strings.HasPrefix(v, "domain:"):
+		return Web, true
+	case strings.HasPrefix(v, "/"), strings.HasPrefix(v, "./"), strings.HasPrefix(v, "../"),
+		strings.HasPrefix(v, "~/"), strings.Contains(v, "**"):
+		return File, true
+	}
+	return Command, false
+}
+
+// ClassifyFor classifies an entry read from a client that accepts the given
+// categories. Plain entries take the client's category when it accepts exactly
+// one, so MCP server names from an MCP-only list are not mistaken for commands.
+func ClassifyFor(value string, accepts []Category) Category {
+	c, ok := Classify(value)
+	if !ok && len(accepts) == 1 {
+		return accepts[0]
+	}
+	return c
+}
+
+// Check returns an error describing malformed entry syntax: unbalanced
+// parentheses, an empty Tool() argument, or a :* wildcard before the end of a
+// command.
+func Check(value string) error {
+	depth := 0
+	var quote rune
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unbalanced parentheses")
+	}
+	m := toolCallRe.FindStringSubmatch(value)
+	if m == nil {
+		return nil
+	}
+	if strings.TrimSpace(m[2]) == "" {
+		return fmt.Errorf("empty argument in %s()", m[1])
+	}
+	if toolCategories[m[1]] == Command {
+		if i := strings.Index(m[2], ":*"); i >= 0 && i != len(m[2])-2 {
+			return fmt.Errorf(":* is only allowed at the end")
+		}
+	}
+	return nil
+}
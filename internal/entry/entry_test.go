@@ -0,0 +1,123 @@
+package entry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		value string
+		want  Category
+		known bool
+	}{
+		{"Bash(git status:*)", Command, true},
+		{"ShellTool(ls)", Command, true},
+		{"mcp__github__create_issue", MCP, true},
+		{"Read(./src/**)", File, true},
+		{"read_file", File, true},
+		{"WebFetch(domain:example.com)", Web, true},
+		{"https://example.com", Web, true},
+		{"TodoWrite", Other, true},
+		{"SomeNewTool(x)", Other, true},
+		{"git status", Command, false},
+		{"github", Command, false},
+	}
+	for _, tc := range cases {
+		got, known := Classify(tc.value)
+		if got != tc.want || known != tc.known {
+			t.Errorf("Classify(%q) = %s, %v; want %s, %v", tc.value, got, known, tc.want, tc.known)
+		}
+	}
+}
+
+func TestClassifyFor(t *testing.T) {
+	if got := ClassifyFor("github", []Category{MCP}); got != MCP {
+		t.Fatalf("plain entry in mcp-only client: got %s", got)
+	}
+	if got := ClassifyFor("Bash(ls)", []Category{MCP}); got != Command {
+		t.Fatalf("explicit syntax should win: got %s", got)
+	}
+	if got := ClassifyFor("github", []Category{Command, MCP}); got != Command {
+		t.Fatalf("plain entry with several accepts: got %s", got)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	cases := []struct {
+		value string
+		want  Cmd
+		ok    bool
+	}{
+		{"Bash(git status:*)", Cmd{Tool: "Bash", Text: "git status", Prefix: true}, true},
+		{"Bash(git status)", Cmd{Tool: "Bash", Text: "git status"}, true},
+		{"Bash(npm run *)", Cmd{Tool: "Bash", Text: "npm run", Prefix: true}, true},
+		{"Bash(*)", Cmd{Tool: "Bash", Prefix: true}, true},
+		{"Bash", Cmd{Tool: "Bash", Prefix: true}, true},
+		{"run_shell_command(git)", Cmd{Tool: "run_shell_command", Text: "git", Prefix: true}, true},
+		{"git status", Cmd{Text: "git status", Prefix: true}, true},
+		{"*", Cmd{Prefix: true}, true},
+		{"Read(./src/**)", Cmd{}, false},
+		{"mcp__github", Cmd{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := ParseCommand(tc.value)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ParseCommand(%q) = %+v, %v; want %+v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	cases := []struct {
+		broad, narrow string
+		want          bool
+	}{
+		{"Bash(git:*)", "Bash(git status)", true},
+		{"Bash(git:*)", "Bash(git status:*)", true},
+		{"Bash(git:*)", "Bash(gitk)", false},
+		{"Bash(git status)", "Bash(git status --short)", false},
+		{"Bash(*)", "Bash(ls)", true},
+		{"git", "git status", true},
+		{"git", "Bash(git status)", false},
+		{"Bash(git status)", "Bash(git status:*)", false},
+		{"Bash(ls)", "Bash(ls)", false},
+		{"mcp__github", "mcp__github__create_issue", false},
+	}
+	for _, tc := range cases {
+		if got := Covers(tc.broad, tc.narrow); got != tc.want {
+			t.Errorf("Covers(%q, %q) = %v; want %v", tc.broad, tc.narrow, got, tc.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, v := range []string{"Bash(git status:*)", `Bash(echo ")")`, "git status", "mcp__github"} {
+		if err := Check(v); err != nil {
+			t.Errorf("Check(%q) = %v", v, err)
+		}
+	}
+	for _, v := range []string{"Bash(git status", "Bash(ls))", "Bash()", "Bash(git:* status)"} {
+		if err := Check(v); err == nil {
+			t.Errorf("Check(%q) should fail", v)
+		}
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	values := []string{"Bash(git status)", "Bash(git:*)", "Bash(git *)", "Bash(git status --short)", "git", "git status", "run_shell_command(git status)", "mcp__git"}
+	kept, collapsed := Collapse(values, func(v string) bool { return v != "mcp__git" })
+	want := []string{"Bash(git:*)", "git", "run_shell_command(git status)", "mcp__git"}
+	if !reflect.DeepEqual(kept, want) {
+		t.Fatalf("kept %q, want %q", kept, want)
+	}
+	wantCollapsed := map[string]string{
+		"Bash(git status)":         "Bash(git:*)",
+		"Bash(git *)":              "Bash(git:*)",
+		"Bash(git status --short)": "Bash(git:*)",
+		"git status":               "git",
+	}
+	if !reflect.DeepEqual(collapsed, wantCollapsed) {
+		t.Fatalf("collapsed %v, want %v", collapsed, wantCollapsed)
+	}
+}
@@ -2,8 +2,10 @@ package format
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -16,16 +18,22 @@ type ListFormat interface {
 	Write(path string, values []string) error
 }
 
-type NewlineFormat struct{}
+// Formats and JSONDocument log the files they write at debug level to Log,
+// when set.
+type NewlineFormat struct {
+	Log *slog.Logger
+}
 
-type JSONArrayFormat struct{}
+type JSONArrayFormat struct {
+	Log *slog.Logger
+}
 
-func New(name string) (ListFormat, error) {
+func New(name string, log *slog.Logger) (ListFormat, error) {
 	switch strings.ToLower(name) {
 	case "newline", "lines", "txt":
-		return NewlineFormat{}, nil
+		return NewlineFormat{Log: log}, nil
 	case "json", "json-array", "jsonarray":
-		return JSONArrayFormat{}, nil
+		return JSONArrayFormat{Log: log}, nil
 	default:
 		return nil, fmt.Errorf("unknown format %q", name)
 	}
@@ -59,7 +67,11 @@ func (f NewlineFormat) Write(path string, values []string) error {
 	if content != "" {
 		content += "\n"
 	}
-	return os.WriteFile(path, []byte(content), 0o644)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return err
+	}
+	debug(f.Log, "wrote file", "path", path, "format", "newline", "entries", len(values))
+	return nil
 }
 
 func (f JSONArrayFormat) Read(path string, missingOK bool) ([]string, error) {
@@ -86,7 +98,17 @@ func (f JSONArrayFormat) Write(path string, values []string) error {
 		return err
 	}
 	b = append(b, '\n')
-	return os.WriteFile(path, b, 0o644)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return err
+	}
+	debug(f.Log, "wrote file", "path", path, "format", "json", "entries", len(values))
+	return nil
+}
+
+func debug(log *slog.Logger, msg string, args ...any) {
+	if log != nil {
+		log.Debug(msg, args...)
+	}
 }
 
 func ensureDir(path string) error {
@@ -162,7 +184,7 @@ func ReadCodexRules(path string, missingOK bool) (allow []string, deny []string,
 	return allow, deny, nil
 }
 
-func WriteCodexRules(path string, allow []string, deny []string) error {
+func WriteCodexRules(path string, allow []string, deny []string, log *slog.Logger) error {
 	kept, err := readCodexRuleFileKeepingNonManaged(path)
 	if err != nil {
 		return err
@@ -184,7 +206,11 @@ func WriteCodexRules(path string, allow []string, deny []string) error {
 	if content != "" {
 		content += "\n"
 	}
-	return os.WriteFile(path, []byte(content), 0o644)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return err
+	}
+	debug(log, "wrote file", "path", path, "format", "codex-rules", "allow", len(allow), "deny", len(deny), "kept_lines", len(kept))
+	return nil
 }
 
 func readCodexRuleFileKeepingNonManaged(path string) ([]string, error) {
@@ -326,14 +352,90 @@ func splitCommandPattern(value string) []string {
 }
 
 func ReadJSONKey(path string, missingOK bool, key string) ([]string, error) {
-	root, err := readJSONObject(path, missingOK)
+	doc, err := readJSONDocument(path, missingOK)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.List(key)
+}
+
+func ReadJSONBoolMap(path string, missingOK bool, key string) ([]string, []string, error) {
+	doc, err := readJSONDocument(path, missingOK)
+	if err != nil || doc == nil {
+		return nil, nil, err
+	}
+	return doc.BoolMap(key)
+}
+
+func WriteJSONBoolMap(path string, key string, allow []string, deny []string) error {
+	doc, err := LoadJSONDocument(path)
+	if err != nil {
+		return err
+	}
+	if err := doc.SetBoolMap(key, allow, deny); err != nil {
+		return err
+	}
+	return doc.Save()
+}
+
+func WriteJSONKey(path string, key string, values []string) error {
+	doc, err := LoadJSONDocument(path)
+	if err != nil {
+		return err
+	}
+	if err := doc.SetList(key, values); err != nil {
+		return err
+	}
+	return doc.Save()
+}
+
+// JSONDocument holds a parsed JSON object so several keys can be read and
+// updated with a single parse and a single write. A missing file loads as an
+// empty document with Missing set.
+type JSONDocument struct {
+	Path    string
+	Missing bool
+	Log     *slog.Logger
+	root    map[string]any
+	raw     []byte
+}
+
+func LoadJSONDocument(path string) (*JSONDocument, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return &JSONDocument{Path: path, Missing: true, root: map[string]any{}}, nil
+		}
+		return nil, err
+	}
+	var root map[string]any
+	if err := json.Unmarshal(b, &root); err != nil {
 		return nil, err
 	}
 	if root == nil {
-		return nil, nil
+		root = map[string]any{}
+	}
+	return &JSONDocument{Path: path, root: root, raw: b}, nil
+}
+
+// readJSONDocument loads path, returning nil for a missing file when
+// missingOK and the not-exist error otherwise.
+func readJSONDocument(path string, missingOK bool) (*JSONDocument, error) {
+	doc, err := LoadJSONDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Missing {
+		if missingOK {
+			return nil, nil
+		}
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
 	}
-	val, ok, err := getJSONPath(root, key)
+	return doc, nil
+}
+
+func (d *JSONDocument) List(key string) ([]string, error) {
+	val, ok, err := getJSONPath(d.root, key)
 	if err != nil {
 		return nil, err
 	}
@@ -347,15 +449,8 @@ func ReadJSONKey(path string, missingOK bool, key string) ([]string, error) {
 	return list, nil
 }
 
-func ReadJSONBoolMap(path string, missingOK bool, key string) ([]string, []string, error) {
-	root, err := readJSONObject(path, missingOK)
-	if err != nil {
-		return nil, nil, err
-	}
-	if root == nil {
-		return nil, nil, nil
-	}
-	val, ok, err := getJSONPath(root, key)
+func (d *JSONDocument) BoolMap(key string) ([]string, []string, error) {
+	val, ok, err := getJSONPath(d.root, key)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -383,14 +478,11 @@ func ReadJSONBoolMap(path string, missingOK bool, key string) ([]string, []strin
 	return allow, deny, nil
 }
 
-func WriteJSONBoolMap(path string, key string, allow []string, deny []string) error {
-	root, err := readJSONObject(path, true)
-	if err != nil {
-		return err
-	}
-	if root == nil {
-		root = map[string]any{}
-	}
+func (d *JSONDocument) SetList(key string, values []string) error {
+	return setJSONPath(d.root, key, values)
+}
+
+func (d *JSONDocument) SetBoolMap(key string, allow []string, deny []string) error {
 	out := make(map[string]any, len(allow)+len(deny))
 	for _, v := range allow {
 		out[v] = true
@@ -398,58 +490,31 @@ func WriteJSONBoolMap(path string, key string, allow []string, deny []string) er
 	for _, v := range deny {
 		out[v] = false
 	}
-	if err := setJSONPath(root, key, out); err != nil {
-		return err
-	}
-	if err := ensureDir(path); err != nil {
-		return err
-	}
-	b, err := json.MarshalIndent(root, "", "  ")
-	if err != nil {
-		return err
-	}
-	b = append(b, '\n')
-	return os.WriteFile(path, b, 0o644)
+	return setJSONPath(d.root, key, out)
 }
 
-func WriteJSONKey(path string, key string, values []string) error {
-	root, err := readJSONObject(path, true)
+// Save writes the document unless its serialized form is identical to the
+// bytes it was loaded from.
+func (d *JSONDocument) Save() error {
+	b, err := json.MarshalIndent(d.root, "", "  ")
 	if err != nil {
 		return err
 	}
-	if root == nil {
-		root = map[string]any{}
-	}
-	if err := setJSONPath(root, key, values); err != nil {
-		return err
+	b = append(b, '\n')
+	if !d.Missing && bytes.Equal(b, d.raw) {
+		debug(d.Log, "file unchanged, not written", "path", d.Path)
+		return nil
 	}
-	if err := ensureDir(path); err != nil {
+	if err := ensureDir(d.Path); err != nil {
 		return err
 	}
-	b, err := json.MarshalIndent(root, "", "  ")
-	if err != nil {
+	if err := os.WriteFile(d.Path, b, 0o644); err != nil {
 		return err
 	}
-	b = append(b, '\n')
-	return os.WriteFile(path, b, 0o644)
-}
-
-func readJSONObject(path string, missingOK bool) (map[string]any, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		if missingOK && os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	var root map[string]any
-	if err := json.Unmarshal(b, &root); err != nil {
-		return nil, err
-	}
-	if root == nil {
-		root = map[string]any{}
-	}
-	return root, nil
+	debug(d.Log, "wrote file", "path", d.Path, "format", "json-object", "bytes", len(b))
+	d.Missing = false
+	d.raw = b
+	return nil
 }
 
 func getJSONPath(root map[string]any, key string) (any, bool, error) {
@@ -114,7 +114,7 @@ func TestCodexRulesReadWrite(t *testing.T) {
 		t.Fatalf("deny mismatch: %v", deny)
 	}
 
-	if err := WriteCodexRules(path, []string{"ls", "git status"}, []string{"rm -rf"}); err != nil {
+	if err := WriteCodexRules(path, []string{"ls", "git status"}, []string{"rm -rf"}, nil); err != nil {
 		t.Fatalf("write rules: %v", err)
 	}
 	allow, deny, err = ReadCodexRules(path, false)
@@ -0,0 +1,199 @@
+// Package guard flags allow entries that grant dangerous commands, using a
+// built-in ruleset and user patterns.
+package guard
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
+)
+
+// What happens to a matching allow entry: Block drops it from the merged
+// allow list, Deny also adds it to the deny list, and Abort fails the run.
+const (
+	Block = "block"
+	Deny  = "deny"
+	Abort = "abort"
+)
+
+var Actions = []string{Block, Deny, Abort}
+
+type Rule struct {
+	Name   string
+	Reason string
+	match  func(value string, cmd entry.Cmd, isCmd bool) bool
+}
+
+var (
+	rootCommands  = []string{"sudo", "doas", "su", "pkexec"}
+	fetchCommands = []string{"curl", "wget"}
+	shells        = []string{"sh", "bash", "zsh", "dash", "ksh", "fish"}
+	substFetchRe  = regexp.MustCompile("(\\$\\(|<\\(|`)\\s*(curl|wget)\\b")
+)
+
+var builtin = []Rule{
+	{
+		Name:   "any-command",
+		Reason: "grants every shell command",
+		match: func(_ string, cmd entry.Cmd, isCmd bool) bool {
+			return isCmd && cmd.Text == "" && cmd.Prefix
+		},
+	},
+	{
+		Name:   "rm-recursive",
+		Reason: "recursive rm can delete whole directory trees",
+		match: func(_ string, cmd entry.Cmd, isCmd bool) bool {
+			if !isCmd {
+				return false
+			}
+			segments := entry.Segments(cmd.Text)
+			for i, words := range segments {
+				words = withoutRoot(words)
+				if len(words) == 0 || words[0] != "rm" {
+					continue
+				}
+				onlyFlags := true
+				for _, arg := range words[1:] {
+					switch {
+					case arg == "--recursive":
+						return true
+					case strings.HasPrefix(arg, "--"):
+					case strings.HasPrefix(arg, "-"):
+						if strings.ContainsAny(arg, "rR") {
+							return true
+						}
+					default:
+						onlyFlags = false
+					}
+				}
+				// A prefix grant of rm without paths also grants rm -rf.
+				if cmd.Prefix && onlyFlags && i == len(segments)-1 {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		Name:   "sudo",
+		Reason: "runs commands as root",
+		match: func(_ string, cmd entry.Cmd, isCmd bool) bool {
+			if !isCmd {
+				return false
+			}
+			for _, words := range entry.Segments(cmd.Text) {
+				if slices.Contains(rootCommands, words[0]) {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		Name:   "pipe-to-shell",
+		Reason: "runs a downloaded script",
+		match: func(_ string, cmd entry.Cmd, isCmd bool) bool {
+			if !isCmd {
+				return false
+			}
+			fetched := false
+			for _, words := range entry.Segments(cmd.Text) {
+				words = withoutRoot(words)
+				if len(words) == 0 {
+					continue
+				}
+				if slices.Contains(fetchCommands, words[0]) {
+					fetched = true
+				} else if fetched && slices.Contains(shells, words[0]) {
+					return true
+				}
+				if slices.Contains(shells, words[0]) && substFetchRe.MatchString(strings.Join(words, " ")) {
+					return true
+				}
+			}
+			return false
+		},
+	},
+}
+
+// Builtin returns the built-in rules.
+func Builtin() []Rule {
+	return slices.Clone(builtin)
+}
+
+// withoutRoot drops a leading sudo or similar so the command it runs is
+// checked.
+func withoutRoot(words []string) []string {
+	if len(words) > 0 && slices.Contains(rootCommands, words[0]) {
+		return words[1:]
+	}
+	return words
+}
+
+// Guard matches allow entries against its rules. Exempt entries never match.
+type Guard struct {
+	Action string
+	rules  []Rule
+	exempt map[string]bool
+}
+
+// New returns a guard with the built-in rules when builtin is set, plus a rule
+// per pattern. Patterns are matched against the whole entry and, for command
+// entries, against the command it grants; * matches any text.
+func New(action string, builtin bool, patterns []string, exempt []string) (*Guard, error) {
+	if action == "" {
+		action = Block
+	}
+	action = strings.ToLower(action)
+	if !slices.Contains(Actions, action) {
+		return nil, fmt.Errorf("unknown guardrail action %q (want block, deny or abort)", action)
+	}
+	g := &Guard{Action: action, exempt: map[string]bool{}}
+	if builtin {
+		g.rules = Builtin()
+	}
+	for _, p := range patterns {
+		if strings.TrimSpace(p) == "" {
+			return nil, fmt.Errorf("empty guardrail pattern")
+		}
+		re := globRegexp(p)
+		g.rules = append(g.rules, Rule{
+			Name:   p,
+			Reason: "matches guardrail pattern",
+			match: func(value string, cmd entry.Cmd, isCmd bool) bool {
+				return re.MatchString(value) || (isCmd && cmd.Text != "" && re.MatchString(cmd.Text))
+			},
+		})
+	}
+	for _, v := range exempt {
+		g.exempt[v] = true
+	}
+	return g, nil
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// Match returns the first rule an allow entry of category c matches. A nil
+// guard matches nothing.
+func (g *Guard) Match(value string, c entry.Category) (Rule, bool) {
+	if g == nil || g.exempt[value] {
+		return Rule{}, false
+	}
+	cmd, isCmd := entry.ParseCommand(value)
+	isCmd = isCmd && c == entry.Command
+	for _, r := range g.rules {
+		if r.match(value, cmd, isCmd) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,71 @@
+// Package lock provides exclusive advisory locks on lock files, used to keep
+// one daemon per config and to serialize read-modify-writes of tool files
+// between syncd processes.
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned when the lock is held by another process or handle.
+var ErrLocked = errors.New("locked by another process")
+
+const pollInterval = 50 * time.Millisecond
+
+type Lock struct {
+	path string
+	f    *os.File
+}
+
+// TryAcquire takes the lock at path without waiting, creating the file and
+// its directory if needed.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{path: path, f: f}, nil
+}
+
+// Acquire takes the lock at path, retrying until timeout elapses.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := TryAcquire(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock %s: timed out after %s: %w", path, timeout, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// File returns the open lock file, for recording the holder's details.
+func (l *Lock) File() *os.File {
+	return l.f
+}
+
+func (l *Lock) Release() error {
+	return unlockFile(l.f)
+}
+
+// PathFor returns the lock file in dir for target, named by a hash of its
+// absolute path so any file can be locked without touching its directory.
+func PathFor(dir string, target string) string {
+	if abs, err := filepath.Abs(target); err == nil {
+		target = abs
+	}
+	sum := sha256.Sum256([]byte(target))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".lock")
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package lock
+
+import "os"
+
+// lockFile only creates the lock file on platforms without advisory locks.
+func lockFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+}
+
+func unlockFile(f *os.File) error {
+	return f.Close()
+}
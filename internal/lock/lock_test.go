@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "a.lock")
+	first, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := TryAcquire(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	start := time.Now()
+	if _, err := Acquire(path, 120*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("acquire returned before the timeout")
+	}
+	if err := first.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	second, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	second.Release()
+}
+
+func TestPathFor(t *testing.T) {
+	dir := t.TempDir()
+	a := PathFor(dir, "/home/u/.claude/settings.json")
+	b := PathFor(dir, "/home/u/.cursor/cli.json")
+	if a == b || filepath.Dir(a) != dir {
+		t.Fatalf("unexpected lock paths %s %s", a, b)
+	}
+	if a != PathFor(dir, "/home/u/.claude/../.claude/settings.json") {
+		t.Fatal("equivalent paths should share a lock")
+	}
+}
@@ -0,0 +1,137 @@
+// Package metrics is a minimal Prometheus text-format registry of labelled
+// counters and gauges.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Registry struct {
+	mu       sync.Mutex
+	families []*Vec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Vec is a metric family: one value per combination of label values.
+type Vec struct {
+	reg    *Registry
+	name   string
+	help   string
+	kind   string
+	labels []string
+	values map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+func (r *Registry) Counter(name string, help string, labels ...string) *Vec {
+	return r.register(name, help, "counter", labels)
+}
+
+func (r *Registry) Gauge(name string, help string, labels ...string) *Vec {
+	return r.register(name, help, "gauge", labels)
+}
+
+func (r *Registry) register(name string, help string, kind string, labels []string) *Vec {
+	v := &Vec{reg: r, name: name, help: help, kind: kind, labels: labels, values: map[string]*series{}}
+	r.mu.Lock()
+	r.families = append(r.families, v)
+	r.mu.Unlock()
+	return v
+}
+
+// Add increases the series for labelValues, which must match the family's
+// labels in number and order.
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.reg.mu.Lock()
+	defer v.reg.mu.Unlock()
+	v.get(labelValues).value += delta
+}
+
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.reg.mu.Lock()
+	defer v.reg.mu.Unlock()
+	v.get(labelValues).value = value
+}
+
+// Reset drops every series, for gauges whose label sets can disappear.
+func (v *Vec) Reset() {
+	v.reg.mu.Lock()
+	defer v.reg.mu.Unlock()
+	v.values = map[string]*series{}
+}
+
+func (v *Vec) get(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\x00")
+	s, ok := v.values[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+// Write renders every family in the Prometheus text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	for _, v := range r.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", v.name, v.kind)
+		keys := make([]string, 0, len(v.values))
+		for k := range v.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := v.values[k]
+			b.WriteString(v.name)
+			if len(v.labels) > 0 {
+				b.WriteByte('{')
+				for i, name := range v.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(s.labels[i]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	reg := NewRegistry()
+	runs := reg.Counter("syncd_runs_total", "Sync runs.", "result")
+	entries := reg.Gauge("syncd_entries", "Entries per list.", "client", "list")
+	last := reg.Gauge("syncd_last_success_timestamp_seconds", "Last success.")
+
+	runs.Add(1, "success")
+	runs.Add(2, "success")
+	runs.Add(1, "failure")
+	entries.Set(3, `we"ird`, "allow")
+	entries.Set(1, "claude", "deny")
+	last.Set(1.5e9)
+
+	var b strings.Builder
+	if err := reg.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP syncd_runs_total Sync runs.
+# TYPE syncd_runs_total counter
+syncd_runs_total{result="failure"} 1
+syncd_runs_total{result="success"} 3
+# HELP syncd_entries Entries per list.
+# TYPE syncd_entries gauge
+syncd_entries{client="claude",list="deny"} 1
+syncd_entries{client="we\"ird",list="allow"} 3
+# HELP syncd_last_success_timestamp_seconds Last success.
+# TYPE syncd_last_success_timestamp_seconds gauge
+syncd_last_success_timestamp_seconds 1.5e+09
+`
+	if b.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	entries.Reset()
+	b.Reset()
+	reg.Write(&b)
+	if strings.Contains(b.String(), "syncd_entries{") {
+		t.Fatal("reset left series behind")
+	}
+}
@@ -0,0 +1,146 @@
+// Package service generates systemd user units and launchd agents that run
+// the syncd daemon, and implements the sd_notify protocol.
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	Systemd = "systemd"
+	Launchd = "launchd"
+)
+
+// DefaultName names the unit (syncd.service) or agent label suffix.
+const DefaultName = "syncd"
+
+// WatchdogSec is the systemd watchdog timeout written to units. The daemon
+// stops pinging when a single run takes longer than this.
+const WatchdogSec = 120
+
+// Unit describes the daemon a service runs.
+type Unit struct {
+	Name     string
+	Binary   string
+	Config   string
+	Interval time.Duration
+	Args     []string
+}
+
+func (u Unit) args() []string {
+	args := []string{u.Binary, "-config", u.Config}
+	if u.Interval > 0 {
+		args = append(args, "-interval", u.Interval.String())
+	}
+	return append(args, u.Args...)
+}
+
+// Path returns where the unit file for system is installed below home, with
+// configHome being $XDG_CONFIG_HOME (or ~/.config).
+func (u Unit) Path(system string, home string, configHome string) (string, error) {
+	switch system {
+	case Systemd:
+		return filepath.Join(configHome, "systemd", "user", u.Name+".service"), nil
+	case Launchd:
+		return filepath.Join(home, "Library", "LaunchAgents", u.Label()+".plist"), nil
+	default:
+		return "", fmt.Errorf("unknown service system %q (want systemd or launchd)", system)
+	}
+}
+
+// Label is the launchd label of the agent.
+func (u Unit) Label() string {
+	return "com.github.hongkongkiwi." + u.Name
+}
+
+var systemdTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Allow/deny list sync ({{.Name}})
+Documentation=https://github.com/hongkongkiwi/codex-claude-allow-deny-sync
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart={{.ExecStart}}
+Restart=on-failure
+RestartSec=10
+WatchdogSec={{.WatchdogSec}}
+
+[Install]
+WantedBy=default.target
+`))
+
+// Systemd renders a systemd user unit with sd_notify readiness and watchdog.
+func (u Unit) Systemd() string {
+	quoted := make([]string, 0, len(u.args()))
+	for _, a := range u.args() {
+		quoted = append(quoted, systemdQuote(a))
+	}
+	var b bytes.Buffer
+	systemdTemplate.Execute(&b, map[string]any{
+		"Name":        u.Name,
+		"ExecStart":   strings.Join(quoted, " "),
+		"WatchdogSec": WatchdogSec,
+	})
+	return b.String()
+}
+
+// systemdQuote escapes the % that would start a specifier and double-quotes
+// words containing spaces, quotes or backslashes for ExecStart.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+var launchdTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>{{xml .Label}}</string>
+  <key>ProgramArguments</key>
+  <array>
+{{- range .Args}}
+    <string>{{xml .}}</string>
+{{- end}}
+  </array>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <dict>
+    <key>SuccessfulExit</key>
+    <false/>
+  </dict>
+  <key>StandardOutPath</key>
+  <string>{{xml .Log}}</string>
+  <key>StandardErrorPath</key>
+  <string>{{xml .Log}}</string>
+</dict>
+</plist>
+`))
+
+// Launchd renders a launchd agent plist that restarts the daemon when it
+// fails and logs to ~/Library/Logs.
+func (u Unit) Launchd(home string) string {
+	var b bytes.Buffer
+	launchdTemplate.Execute(&b, map[string]any{
+		"Label": u.Label(),
+		"Args":  u.args(),
+		"Log":   filepath.Join(home, "Library", "Logs", u.Name+".log"),
+	})
+	return b.String()
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	u := Unit{Name: "syncd-mcp", Binary: "/usr/local/bin/syncd", Config: "/home/me/My Configs/100%.yaml", Interval: time.Minute}
+	got := u.Systemd()
+	for _, want := range []string{
+		`ExecStart=/usr/local/bin/syncd -config "/home/me/My Configs/100%%.yaml" -interval 1m0s`,
+		"Type=notify",
+		"WatchdogSec=120",
+		"Description=Allow/deny list sync (syncd-mcp)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("unit missing %q:\n%s", want, got)
+		}
+	}
+	path, err := u.Path(Systemd, "/home/me", "/home/me/.config")
+	if err != nil || path != "/home/me/.config/systemd/user/syncd-mcp.service" {
+		t.Fatalf("path %q %v", path, err)
+	}
+
+	plist := Unit{Name: "syncd", Binary: "/bin/syncd", Config: "/a&b.yaml"}.Launchd("/Users/me")
+	if !strings.Contains(plist, "<string>/a&amp;b.yaml</string>") || !strings.Contains(plist, "<string>com.github.hongkongkiwi.syncd</string>") {
+		t.Fatalf("unexpected plist:\n%s", plist)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram not supported: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", addr)
+	if err := Notify("READY=1"); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Fatalf("got %q %v", buf[:n], err)
+	}
+
+	t.Setenv("WATCHDOG_USEC", "120000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 2*time.Minute {
+		t.Fatalf("watchdog interval %s", got)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := WatchdogInterval(); got != 0 {
+		t.Fatalf("watchdog for another pid: %s", got)
+	}
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify("READY=1"); err != nil {
+		t.Fatalf("notify without socket: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/audit"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/format"
+)
+
+// Origins maps each merged entry to the client it was first read from.
+type Origins struct {
+	Allow map[string]string
+	Deny  map[string]string
+}
+
+func newOrigins() Origins {
+	return Origins{Allow: map[string]string{}, Deny: map[string]string{}}
+}
+
+func (o Origins) add(client string, policy Policy) {
+	for _, v := range policy.Allow {
+		if _, ok := o.Allow[v]; !ok {
+			o.Allow[v] = client
+		}
+	}
+	for _, v := range policy.Deny {
+		if _, ok := o.Deny[v]; !ok {
+			o.Deny[v] = client
+		}
+	}
+}
+
+// Change is one entry a run adds to or removes from one list of a client.
+type Change struct {
+	Group  string
+	Client string
+	File   string
+	List   string
+	Entry  string
+	Action string
+	Origin string
+}
+
+// ClientDrift is how far a written client is from the merged policy: the
+// entries a sync would add (Missing) and remove (Extra). Project files from
+// workspaces have no Group and are named by their path.
+type ClientDrift struct {
+	Group   string `json:"group,omitempty"`
+	Client  string `json:"client"`
+	Missing Policy `json:"missing"`
+	Extra   Policy `json:"extra"`
+}
+
+func (d ClientDrift) InSync() bool {
+	return len(d.Missing.Allow)+len(d.Missing.Deny)+len(d.Extra.Allow)+len(d.Extra.Deny) == 0
+}
+
+// Drift lists every written client of a run with its changes, including
+// clients that are already in sync. Run it with DryRun to report drift
+// without writing.
+func Drift(result Result) []ClientDrift {
+	var out []ClientDrift
+	index := map[[2]string]int{}
+	add := func(group string, client string) {
+		index[[2]string{group, client}] = len(out)
+		out = append(out, ClientDrift{
+			Group:   group,
+			Client:  client,
+			Missing: Policy{Allow: []string{}, Deny: []string{}},
+			Extra:   Policy{Allow: []string{}, Deny: []string{}},
+		})
+	}
+	for _, group := range result.Groups {
+		for _, snap := range group.Clients {
+			if snap.Client.Writes() {
+				add(group.Name, snap.Client.Name)
+			}
+		}
+	}
+	for _, project := range result.Projects {
+		add("", project.Client.Name)
+	}
+	for _, c := range result.Changes {
+		i, ok := index[[2]string{c.Group, c.Client}]
+		if !ok {
+			continue
+		}
+		p := &out[i].Extra
+		if c.Action == audit.Added {
+			p = &out[i].Missing
+		}
+		if c.List == "allow" {
+			p.Allow = append(p.Allow, c.Entry)
+		} else {
+			p.Deny = append(p.Deny, c.Entry)
+		}
+	}
+	return out
+}
+
+func newRunID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// clientChanges compares what a writable client holds with what will be
+// written to it, for the lists that are written. Clients that are not read
+// are read here for the comparison.
+func clientChanges(files *fileSet, group string, client config.Client, before Policy, target Policy, origins Origins) ([]Change, error) {
+	if !client.Reads() {
+		probe := client
+		probe.MissingOK = true
+		allow, deny, err := files.read(probe)
+		if err != nil {
+			return nil, err
+		}
+		before = Policy{Allow: format.Normalize(allow, false), Deny: format.Normalize(deny, false)}
+	}
+	var out []Change
+	diff := func(list string, file string, before []string, after []string, origins map[string]string) {
+		if !writesList(client, list) {
+			return
+		}
+		had := toSet(before)
+		has := toSet(after)
+		for _, v := range after {
+			if !had[v] {
+				out = append(out, Change{Group: group, Client: client.Name, File: file, List: list, Entry: v, Action: audit.Added, Origin: origins[v]})
+			}
+		}
+		for _, v := range before {
+			if !has[v] {
+				out = append(out, Change{Group: group, Client: client.Name, File: file, List: list, Entry: v, Action: audit.Removed})
+			}
+		}
+	}
+	diff("allow", allowFile(client), before.Allow, target.Allow, origins.Allow)
+	diff("deny", denyFile(client), before.Deny, target.Deny, origins.Deny)
+	return out, nil
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func auditRecords(runID string, at time.Time, changes []Change) []audit.Record {
+	records := make([]audit.Record, 0, len(changes))
+	for _, c := range changes {
+		records = append(records, audit.Record{
+			Time:   at,
+			RunID:  runID,
+			Group:  c.Group,
+			Client: c.Client,
+			File:   c.File,
+			List:   c.List,
+			Entry:  c.Entry,
+			Action: c.Action,
+			Origin: c.Origin,
+		})
+	}
+	return records
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
+)
+
+// Collapsed is an entry left out of a client's list because a broader entry
+// of the same list, By, already grants it. Group is empty for workspace
+// project files, whose Client is the file's path.
+type Collapsed struct {
+	Group  string
+	Client string
+	List   string
+	Entry  string
+	By     string
+}
+
+// dedupes reports whether the dedupe pass applies to a client: it must be on
+// for the group and not turned off for the client.
+func dedupes(group *bool, client config.Client) bool {
+	return group != nil && *group && (client.Dedupe == nil || *client.Dedupe)
+}
+
+// dedupePolicy collapses command entries covered by a broader entry of the
+// same list.
+func dedupePolicy(group string, client string, policy Policy, category func(string) entry.Category) (Policy, []Collapsed) {
+	isCommand := func(v string) bool { return category(v) == entry.Command }
+	var out []Collapsed
+	collapse := func(list string, values []string) []string {
+		kept, collapsed := entry.Collapse(values, isCommand)
+		for _, v := range values {
+			if by, ok := collapsed[v]; ok {
+				out = append(out, Collapsed{Group: group, Client: client, List: list, Entry: v, By: by})
+			}
+		}
+		return kept
+	}
+	return Policy{Allow: collapse("allow", policy.Allow), Deny: collapse("deny", policy.Deny)}, out
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"os"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+type Discovery struct {
+	Preset config.Preset
+	Client config.Client
+	Found  bool
+	Allow  int
+	Deny   int
+	Err    error
+}
+
+// Discover probes the default location of every preset under home and counts
+// the entries each existing file holds.
+func Discover(home string, goos string) []Discovery {
+	var out []Discovery
+	for _, p := range config.Presets() {
+		client := p.Client(goos, home)
+		d := Discovery{Preset: p, Client: client}
+		if _, err := os.Stat(primaryPath(client)); err != nil {
+			if !os.IsNotExist(err) {
+				d.Err = err
+			}
+			out = append(out, d)
+			continue
+		}
+		d.Found = true
+		allow, deny, err := readClient(client)
+		if err != nil {
+			d.Err = err
+		} else {
+			d.Allow = len(allow)
+			d.Deny = len(deny)
+		}
+		out = append(out, d)
+	}
+	return out
+}
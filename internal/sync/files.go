@@ -0,0 +1,432 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/format"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/lock"
+)
+
+// fileSet caches parsed JSON documents per path for one run, so clients that
+// share a file, even across groups and workspaces, parse it once and write it
+// at most once. Only documents a client wrote to are saved, and those whose
+// content is unchanged are not rewritten. Writes of other formats are queued
+// too, so flush can check every file before any is written. With a lock dir
+// set, each file is locked from its first read until release, so other syncd
+// processes cannot interleave their read-modify-writes.
+type fileSet struct {
+	docs    map[string]*format.JSONDocument
+	order   []string
+	written map[string]bool
+	log     *slog.Logger
+
+	lockDir     string
+	lockTimeout time.Duration
+	locks       map[string]*lock.Lock
+
+	prints  map[string]fingerprint
+	tracked []string
+	pending []func() error
+}
+
+// fingerprint records a file's content as it was read, so a write can detect
+// that the tool changed it in the meantime.
+type fingerprint struct {
+	exists  bool
+	modTime time.Time
+	hash    [sha256.Size]byte
+}
+
+func fingerprintFile(path string) (fingerprint, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fingerprint{}, nil
+	}
+	if err != nil {
+		return fingerprint{}, err
+	}
+	fp := fingerprint{exists: true, hash: sha256.Sum256(b)}
+	if info, err := os.Stat(path); err == nil {
+		fp.modTime = info.ModTime()
+	}
+	return fp, nil
+}
+
+func newFileSet() *fileSet {
+	return &fileSet{
+		docs:    map[string]*format.JSONDocument{},
+		written: map[string]bool{},
+		locks:   map[string]*lock.Lock{},
+		prints:  map[string]fingerprint{},
+		log:     slog.New(discardHandler{}),
+	}
+}
+
+// fileKey is the absolute, clean form of path, so that two spellings of one
+// file share a cache entry and a fingerprint, as they share a lock.
+func fileKey(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// track records the fingerprint of each path the first time it is read.
+func (f *fileSet) track(paths ...string) error {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		path = fileKey(path)
+		if _, ok := f.prints[path]; ok {
+			continue
+		}
+		fp, err := fingerprintFile(path)
+		if err != nil {
+			return err
+		}
+		f.prints[path] = fp
+		f.tracked = append(f.tracked, path)
+	}
+	return nil
+}
+
+// check returns ErrConflict if path changed since it was first read. Paths
+// that were never read are not checked.
+func (f *fileSet) check(path string) error {
+	path = fileKey(path)
+	before, ok := f.prints[path]
+	if !ok {
+		return nil
+	}
+	now, err := fingerprintFile(path)
+	if err != nil {
+		return err
+	}
+	if now.exists != before.exists || now.hash != before.hash {
+		if now.exists {
+			return fmt.Errorf("%s (modified %s): %w", path, now.modTime.Format(time.RFC3339), ErrConflict)
+		}
+		return fmt.Errorf("%s (removed): %w", path, ErrConflict)
+	}
+	return nil
+}
+
+// verify checks every file read so far.
+func (f *fileSet) verify() error {
+	for _, path := range f.tracked {
+		if err := f.check(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fileSet) lock(paths ...string) error {
+	if f.lockDir == "" {
+		return nil
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		name := lock.PathFor(f.lockDir, path)
+		if _, ok := f.locks[name]; ok {
+			continue
+		}
+		l, err := lock.Acquire(name, f.lockTimeout)
+		if err != nil {
+			return fmt.Errorf("lock %s: %w", path, err)
+		}
+		f.log.Debug("locked file", "path", path, "lock", name)
+		f.locks[name] = l
+	}
+	return nil
+}
+
+// lockAll locks every path in order of absolute path. Later lock calls for
+// the same files are then no-ops, so a run sharing files with another never
+// holds one lock while waiting for a lock the other holds.
+func (f *fileSet) lockAll(paths []string) error {
+	keys := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path != "" {
+			keys = append(keys, fileKey(path))
+		}
+	}
+	sort.Strings(keys)
+	return f.lock(slices.Compact(keys)...)
+}
+
+func (f *fileSet) release() {
+	for name, l := range f.locks {
+		l.Release()
+		delete(f.locks, name)
+	}
+}
+
+func (f *fileSet) document(path string) (*format.JSONDocument, error) {
+	path = fileKey(path)
+	if doc, ok := f.docs[path]; ok {
+		return doc, nil
+	}
+	if err := f.lock(path); err != nil {
+		return nil, err
+	}
+	if err := f.track(path); err != nil {
+		return nil, err
+	}
+	doc, err := format.LoadJSONDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	doc.Log = f.log
+	f.docs[path] = doc
+	f.order = append(f.order, path)
+	return doc, nil
+}
+
+// existing returns the cached document for path, or nil when the file is
+// missing and missingOK is set.
+func (f *fileSet) existing(path string, missingOK bool) (*format.JSONDocument, error) {
+	doc, err := f.document(path)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Missing {
+		if missingOK {
+			return nil, nil
+		}
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return doc, nil
+}
+
+func (f *fileSet) read(client config.Client) (allow []string, deny []string, err error) {
+	switch strings.ToLower(client.Format) {
+	case "json-object":
+		if primaryPath(client) == "" {
+			return nil, nil, fmt.Errorf("client %s: json-object requires allow_path or deny_path", client.Name)
+		}
+		if client.AllowKey == "" && client.DenyKey == "" {
+			return nil, nil, fmt.Errorf("client %s: json-object requires allow_key or deny_key", client.Name)
+		}
+		if client.AllowKey != "" {
+			allow, err = f.readList(allowFile(client), client.MissingOK, client.AllowKey)
+			if err != nil {
+				return nil, nil, fmt.Errorf("client %s allow: %w", client.Name, err)
+			}
+		}
+		if client.DenyKey != "" {
+			deny, err = f.readList(denyFile(client), client.MissingOK, client.DenyKey)
+			if err != nil {
+				return nil, nil, fmt.Errorf("client %s deny: %w", client.Name, err)
+			}
+		}
+	case "json-bool-map":
+		if primaryPath(client) == "" {
+			return nil, nil, fmt.Errorf("client %s: json-bool-map requires allow_path or deny_path", client.Name)
+		}
+		if client.AllowKey == "" {
+			return nil, nil, fmt.Errorf("client %s: json-bool-map requires allow_key", client.Name)
+		}
+		allow, deny, err = f.readBoolMap(allowFile(client), client.MissingOK, client.AllowKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client %s allow/deny: %w", client.Name, err)
+		}
+		if path := denyFile(client); fileKey(path) != fileKey(allowFile(client)) {
+			moreAllow, moreDeny, err := f.readBoolMap(path, client.MissingOK, boolMapDenyKey(client))
+			if err != nil {
+				return nil, nil, fmt.Errorf("client %s deny: %w", client.Name, err)
+			}
+			allow = append(allow, moreAllow...)
+			deny = append(deny, moreDeny...)
+		}
+	case "codex-rules":
+		path := primaryPath(client)
+		if path == "" {
+			return nil, nil, fmt.Errorf("client %s: codex-rules requires allow_path or deny_path", client.Name)
+		}
+		if err := f.lock(path); err != nil {
+			return nil, nil, fmt.Errorf("client %s rules: %w", client.Name, err)
+		}
+		if err := f.track(path); err != nil {
+			return nil, nil, fmt.Errorf("client %s rules: %w", client.Name, err)
+		}
+		allow, deny, err = format.ReadCodexRules(path, client.MissingOK)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client %s rules: %w", client.Name, err)
+		}
+	default:
+		fmtter, err := format.New(client.Format, f.log)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client %s: %w", client.Name, err)
+		}
+		if err := f.lock(client.AllowPath, client.DenyPath); err != nil {
+			return nil, nil, fmt.Errorf("client %s: %w", client.Name, err)
+		}
+		if err := f.track(client.AllowPath, client.DenyPath); err != nil {
+			return nil, nil, fmt.Errorf("client %s: %w", client.Name, err)
+		}
+		allow, err = fmtter.Read(client.AllowPath, client.MissingOK)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client %s allow: %w", client.Name, err)
+		}
+		deny, err = fmtter.Read(client.DenyPath, client.MissingOK)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client %s deny: %w", client.Name, err)
+		}
+	}
+	return allow, deny, nil
+}
+
+func (f *fileSet) write(client config.Client, policy Policy) error {
+	switch strings.ToLower(client.Format) {
+	case "json-object":
+		if primaryPath(client) == "" {
+			return fmt.Errorf("client %s: json-object requires allow_path or deny_path", client.Name)
+		}
+		if writesList(client, "allow") {
+			doc, err := f.target(allowFile(client))
+			if err != nil {
+				return fmt.Errorf("client %s allow write: %w", client.Name, err)
+			}
+			if err := doc.SetList(client.AllowKey, policy.Allow); err != nil {
+				return fmt.Errorf("client %s allow write: %w", client.Name, err)
+			}
+		}
+		if writesList(client, "deny") {
+			doc, err := f.target(denyFile(client))
+			if err != nil {
+				return fmt.Errorf("client %s deny write: %w", client.Name, err)
+			}
+			if err := doc.SetList(client.DenyKey, policy.Deny); err != nil {
+				return fmt.Errorf("client %s deny write: %w", client.Name, err)
+			}
+		}
+	case "json-bool-map":
+		if primaryPath(client) == "" {
+			return fmt.Errorf("client %s: json-bool-map requires allow_path or deny_path", client.Name)
+		}
+		if client.AllowKey == "" {
+			return fmt.Errorf("client %s: json-bool-map requires allow_key", client.Name)
+		}
+		doc, err := f.target(allowFile(client))
+		if err != nil {
+			return fmt.Errorf("client %s write: %w", client.Name, err)
+		}
+		if fileKey(denyFile(client)) == fileKey(allowFile(client)) {
+			if err := doc.SetBoolMap(client.AllowKey, policy.Allow, policy.Deny); err != nil {
+				return fmt.Errorf("client %s allow/deny write: %w", client.Name, err)
+			}
+			break
+		}
+		if err := doc.SetBoolMap(client.AllowKey, policy.Allow, nil); err != nil {
+			return fmt.Errorf("client %s allow write: %w", client.Name, err)
+		}
+		doc, err = f.target(denyFile(client))
+		if err != nil {
+			return fmt.Errorf("client %s write: %w", client.Name, err)
+		}
+		if err := doc.SetBoolMap(boolMapDenyKey(client), nil, policy.Deny); err != nil {
+			return fmt.Errorf("client %s deny write: %w", client.Name, err)
+		}
+	case "codex-rules":
+		path := primaryPath(client)
+		if path == "" {
+			return fmt.Errorf("client %s: codex-rules requires allow_path or deny_path", client.Name)
+		}
+		if err := f.lock(path); err != nil {
+			return fmt.Errorf("client %s rules write: %w", client.Name, err)
+		}
+		f.pending = append(f.pending, func() error {
+			if err := format.WriteCodexRules(path, policy.Allow, policy.Deny, f.log); err != nil {
+				return fmt.Errorf("client %s rules write: %w", client.Name, err)
+			}
+			return nil
+		})
+	default:
+		fmtter, err := format.New(client.Format, f.log)
+		if err != nil {
+			return fmt.Errorf("client %s: %w", client.Name, err)
+		}
+		if err := f.lock(client.AllowPath, client.DenyPath); err != nil {
+			return fmt.Errorf("client %s: %w", client.Name, err)
+		}
+		f.pending = append(f.pending, func() error {
+			if err := fmtter.Write(client.AllowPath, policy.Allow); err != nil {
+				return fmt.Errorf("client %s allow write: %w", client.Name, err)
+			}
+			if err := fmtter.Write(client.DenyPath, policy.Deny); err != nil {
+				return fmt.Errorf("client %s deny write: %w", client.Name, err)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+func (f *fileSet) readList(path string, missingOK bool, key string) ([]string, error) {
+	doc, err := f.existing(path, missingOK)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.List(key)
+}
+
+func (f *fileSet) readBoolMap(path string, missingOK bool, key string) ([]string, []string, error) {
+	doc, err := f.existing(path, missingOK)
+	if err != nil || doc == nil {
+		return nil, nil, err
+	}
+	return doc.BoolMap(key)
+}
+
+// target returns the document for path and marks it to be saved on flush.
+func (f *fileSet) target(path string) (*format.JSONDocument, error) {
+	doc, err := f.document(path)
+	if err != nil {
+		return nil, err
+	}
+	f.written[fileKey(path)] = true
+	return doc, nil
+}
+
+// flush checks every file read in the run, then saves the documents written
+// to and runs the queued writes. A conflict leaves every file untouched.
+func (f *fileSet) flush() error {
+	if err := f.verify(); err != nil {
+		return err
+	}
+	for _, path := range f.order {
+		if !f.written[path] {
+			continue
+		}
+		if err := f.docs[path].Save(); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	for _, write := range f.pending {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	f.pending = nil
+	return nil
+}
+
+func readClient(client config.Client) (allow []string, deny []string, err error) {
+	return newFileSet().read(client)
+}
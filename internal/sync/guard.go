@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/format"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/guard"
+)
+
+// Blocked is an allow entry a guardrail stopped from being synced. Project is
+// set, and Group empty, for entries of workspace project files.
+type Blocked struct {
+	Group   string
+	Project string
+	Entry   string
+	Origin  string
+	Rule    string
+	Reason  string
+	Action  string
+}
+
+// GuardError fails a run when the guardrail action is abort and any allow
+// entry was blocked. Nothing is written.
+type GuardError struct {
+	Blocked []Blocked
+}
+
+func (e *GuardError) Error() string {
+	parts := make([]string, 0, len(e.Blocked))
+	for _, b := range e.Blocked {
+		part := fmt.Sprintf("%q (%s)", b.Entry, b.Rule)
+		if b.Origin != "" {
+			part = fmt.Sprintf("%q from %s (%s)", b.Entry, b.Origin, b.Rule)
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("guardrails blocked %d allow entries: %s", len(e.Blocked), strings.Join(parts, ", "))
+}
+
+func newGuard(g config.Guardrails) (*guard.Guard, error) {
+	builtin := g.Builtin == nil || *g.Builtin
+	return guard.New(g.Action, builtin, g.Patterns, g.Exempt)
+}
+
+// guardPolicy removes the allow entries g matches, also adding them to the
+// deny list for the deny action, and returns what it removed. With the block
+// action, keepOwn puts removed entries back for the clients holding them.
+func guardPolicy(g *guard.Guard, policy Policy, origins Origins, categories map[string]entry.Category, sortLists bool) (Policy, []Blocked) {
+	var blocked []Blocked
+	allow := make([]string, 0, len(policy.Allow))
+	for _, v := range policy.Allow {
+		c, ok := categories[v]
+		if !ok {
+			c, _ = entry.Classify(v)
+		}
+		rule, ok := g.Match(v, c)
+		if !ok {
+			allow = append(allow, v)
+			continue
+		}
+		blocked = append(blocked, Blocked{Entry: v, Origin: origins.Allow[v], Rule: rule.Name, Reason: rule.Reason, Action: g.Action})
+	}
+	if len(blocked) == 0 {
+		return policy, nil
+	}
+	out := Policy{Allow: allow, Deny: policy.Deny}
+	if g.Action == guard.Deny {
+		deny := append([]string{}, policy.Deny...)
+		for _, b := range blocked {
+			deny = append(deny, b.Entry)
+			if _, ok := origins.Deny[b.Entry]; !ok && origins.Deny != nil {
+				origins.Deny[b.Entry] = b.Origin
+			}
+		}
+		out.Deny = format.Normalize(deny, sortLists)
+	}
+	return out, blocked
+}
+
+// keepOwn adds the blocked entries own already allows back to target, so the
+// block action stops an entry from spreading without taking it from the
+// clients it came from.
+func keepOwn(target Policy, own []string, blocked []Blocked, sortLists bool) Policy {
+	var kept []string
+	for _, b := range blocked {
+		if b.Action == guard.Block && slices.Contains(own, b.Entry) {
+			kept = append(kept, b.Entry)
+		}
+	}
+	if len(kept) == 0 {
+		return target
+	}
+	target.Allow = format.Normalize(append(append([]string{}, target.Allow...), kept...), sortLists)
+	return target
+}
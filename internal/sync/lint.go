@@ -0,0 +1,214 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
+)
+
+// LintRule is one check made by Lint, with the severity of its findings.
+type LintRule struct {
+	ID          string
+	Severity    Severity
+	Description string
+}
+
+var LintRules = []LintRule{
+	{"allow-deny-conflict", SeverityError, "Entry is in both the allow and the deny list."},
+	{"malformed", SeverityError, "Entry syntax is malformed, such as unbalanced parentheses in Bash(...)."},
+	{"guardrail", SeverityError, "Allow entry is blocked by a guardrail."},
+	{"generic-prefix", SeverityWarning, "Allow entry grants every command, or every use of one command; interpreters that run arbitrary code are errors."},
+	{"shadowed-deny", SeverityWarning, "Deny entry is also granted by a broader allow entry."},
+	{"category-mismatch", SeverityWarning, "Entry is of a category the client holding it does not accept."},
+	{"subsumed-allow", SeverityInfo, "Allow entry is already granted by a broader allow entry in the same list."},
+}
+
+// interpreters run arbitrary code given as arguments, so granting any use of
+// them is as broad as granting every command.
+var interpreters = []string{"bash", "sh", "zsh", "fish", "python", "python3", "node", "ruby", "perl", "php", "eval", "env", "xargs", "npx"}
+
+// Lint reports problems with the policy of a run, usually a dry run: in the
+// lists read from each client and project file, and in the merged policy of
+// each group. Findings in the merged policy that a client's lists already
+// show are not repeated.
+func Lint(cfg config.Config, result Result) Problems {
+	l := &linter{seen: map[string]bool{}, lines: map[string][]string{}}
+	groups := map[string]config.Group{}
+	for _, g := range cfg.AllGroups() {
+		groups[g.Name] = g
+	}
+	for _, res := range result.Groups {
+		for _, snap := range res.Clients {
+			if !snap.Client.Reads() {
+				continue
+			}
+			accepts, _ := clientAccepts(snap.Client)
+			sc := lintScope{group: res.Name, client: snap.Client, label: "client " + snap.Client.Name + ": "}
+			l.lists(sc, snap.Policy, func(v string) entry.Category { return entry.ClassifyFor(v, accepts) }, accepts)
+		}
+		sc := lintScope{group: res.Name, pos: groups[res.Name].Pos.Of("")}
+		l.lists(sc, res.Policy, func(v string) entry.Category {
+			if c, ok := res.Categories[v]; ok {
+				return c
+			}
+			c, _ := entry.Classify(v)
+			return c
+		}, nil)
+		for _, b := range res.Blocked {
+			l.add(sc, "guardrail", SeverityError, "allow", b.Entry, fmt.Sprintf("allow %q from %s is blocked by guardrail %s: %s", b.Entry, b.Origin, b.Rule, b.Reason))
+		}
+	}
+	for _, project := range result.Projects {
+		sc := lintScope{client: project.Client}
+		l.lists(sc, project.Policy, func(v string) entry.Category {
+			c, _ := entry.Classify(v)
+			return c
+		}, nil)
+		for _, b := range project.Blocked {
+			l.add(sc, "guardrail", SeverityError, "allow", b.Entry, fmt.Sprintf("allow %q is blocked by guardrail %s: %s", b.Entry, b.Rule, b.Reason))
+		}
+	}
+	return l.problems
+}
+
+// lintScope is where findings are reported: a client's files, or the
+// group's position in the config for its merged policy.
+type lintScope struct {
+	group  string
+	client config.Client
+	label  string
+	pos    config.Position
+}
+
+type linter struct {
+	problems Problems
+	seen     map[string]bool
+	lines    map[string][]string
+}
+
+func (l *linter) lists(sc lintScope, policy Policy, category func(string) entry.Category, accepts []entry.Category) {
+	allow := toSet(policy.Allow)
+	deny := toSet(policy.Deny)
+	malformed := map[string]bool{}
+	for _, list := range []string{"allow", "deny"} {
+		values := policy.Allow
+		if list == "deny" {
+			values = policy.Deny
+		}
+		for _, v := range values {
+			if err := entry.Check(v); err != nil {
+				malformed[v] = true
+				l.add(sc, "malformed", SeverityError, list, v, fmt.Sprintf("%s %q is malformed: %v", list, v, err))
+				continue
+			}
+			if c, known := entry.Classify(v); known && len(accepts) > 0 && !slices.Contains(accepts, c) {
+				l.add(sc, "category-mismatch", SeverityWarning, list, v, fmt.Sprintf("%s %q has category %s but the client accepts %s", list, v, c, joinCategories(accepts)))
+			}
+		}
+	}
+	for _, v := range policy.Allow {
+		if deny[v] {
+			l.add(sc, "allow-deny-conflict", SeverityError, "allow", v, fmt.Sprintf("%q is in both allow and deny", v))
+		}
+		if malformed[v] || category(v) != entry.Command {
+			continue
+		}
+		if cmd, ok := entry.ParseCommand(v); ok && cmd.Prefix {
+			words := strings.Fields(cmd.Text)
+			switch {
+			case len(words) == 0:
+				l.add(sc, "generic-prefix", SeverityError, "allow", v, fmt.Sprintf("allow %q grants every command", v))
+			case len(words) == 1 && slices.Contains(interpreters, words[0]):
+				l.add(sc, "generic-prefix", SeverityError, "allow", v, fmt.Sprintf("allow %q grants any use of %s, which runs arbitrary code", v, words[0]))
+			case len(words) == 1:
+				l.add(sc, "generic-prefix", SeverityWarning, "allow", v, fmt.Sprintf("allow %q grants every %s command", v, words[0]))
+			}
+		}
+		for _, broad := range policy.Allow {
+			if !malformed[broad] && category(broad) == entry.Command && entry.Covers(broad, v) {
+				l.add(sc, "subsumed-allow", SeverityInfo, "allow", v, fmt.Sprintf("allow %q is already granted by %q", v, broad))
+				break
+			}
+		}
+	}
+	for _, v := range policy.Deny {
+		// An entry in both lists is reported as a conflict.
+		if allow[v] || malformed[v] || category(v) != entry.Command {
+			continue
+		}
+		for _, broad := range policy.Allow {
+			if !malformed[broad] && category(broad) == entry.Command && entry.Covers(broad, v) {
+				l.add(sc, "shadowed-deny", SeverityWarning, "deny", v, fmt.Sprintf("deny %q is also granted by allow %q; tools that check allow first will permit it", v, broad))
+				break
+			}
+		}
+	}
+}
+
+func (l *linter) add(sc lintScope, rule string, severity Severity, list string, value string, msg string) {
+	key := strings.Join([]string{rule, sc.group, list, value}, "\x00")
+	if sc.client.Name == "" && l.seen[key] {
+		return
+	}
+	l.seen[key] = true
+	pos := sc.pos
+	if sc.client.Name != "" {
+		file := allowFile(sc.client)
+		if list == "deny" {
+			file = denyFile(sc.client)
+		}
+		pos = config.Position{File: file}
+		if line := l.line(file, value); line > 0 {
+			pos.Line, pos.Column = line, 1
+		}
+	}
+	l.problems = append(l.problems, Problem{
+		Severity: severity,
+		Rule:     rule,
+		Message:  sc.label + msg,
+		Group:    sc.group,
+		Client:   sc.client.Name,
+		List:     list,
+		Entry:    value,
+		File:     pos.File,
+		Line:     pos.Line,
+		Column:   pos.Column,
+	})
+}
+
+// line returns the 1-based line of path holding value as a JSON string, with
+// or without HTML escaping, or as a whole line, or 0.
+func (l *linter) line(path string, value string) int {
+	lines, ok := l.lines[path]
+	if !ok {
+		data, _ := os.ReadFile(path)
+		lines = strings.Split(string(data), "\n")
+		l.lines[path] = lines
+	}
+	escaped, _ := json.Marshal(value)
+	var plain bytes.Buffer
+	enc := json.NewEncoder(&plain)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	quoted := []string{string(escaped), strings.TrimSpace(plain.String())}
+	for i, line := range lines {
+		if strings.Contains(line, quoted[0]) || strings.Contains(line, quoted[1]) || strings.TrimSpace(line) == value {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func joinCategories(cats []entry.Category) string {
+	names := make([]string, len(cats))
+	for i, c := range cats {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/audit"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+// discardHandler drops every record; it stands in when Options.Logger is nil.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func logger(opts Options) *slog.Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	return slog.New(discardHandler{})
+}
+
+// logSummary logs one line per run with its duration, total changes and the
+// entries added and removed per changed client.
+func logSummary(log *slog.Logger, result Result, dryRun bool, elapsed time.Duration) {
+	type counts struct{ added, removed int }
+	per := map[string]*counts{}
+	var order []string
+	for _, c := range result.Changes {
+		key := c.Client
+		if c.Group != "" && c.Group != config.DefaultGroup {
+			key = c.Group + "/" + c.Client
+		}
+		n, ok := per[key]
+		if !ok {
+			n = &counts{}
+			per[key] = n
+			order = append(order, key)
+		}
+		if c.Action == audit.Added {
+			n.added++
+		} else {
+			n.removed++
+		}
+	}
+	clients := make([]any, 0, len(order))
+	for _, key := range order {
+		clients = append(clients, slog.Group(key, "added", per[key].added, "removed", per[key].removed))
+	}
+	msg := "sync complete"
+	if dryRun {
+		msg = "dry run complete"
+	}
+	log.Info(msg,
+		"duration", elapsed,
+		"groups", len(result.Groups),
+		"projects", len(result.Projects),
+		"changes", len(result.Changes),
+		"retries", result.Retries,
+		"collapsed", len(result.Collapsed),
+		slog.Group("clients", clients...),
+	)
+}
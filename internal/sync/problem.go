@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"strings"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Problem is one validation or lint finding, located at the YAML node
+// responsible for it when the config was loaded from a file, or at the line
+// of a client's file holding the entry. Lint findings name their Rule and
+// the List and Entry they concern.
+type Problem struct {
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule,omitempty"`
+	Message  string   `json:"message"`
+	Group    string   `json:"group,omitempty"`
+	Client   string   `json:"client,omitempty"`
+	List     string   `json:"list,omitempty"`
+	Entry    string   `json:"entry,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+}
+
+func (p Problem) String() string {
+	pos := config.Position{File: p.File, Line: p.Line, Column: p.Column}.String()
+	msg := p.Message
+	if p.Group != "" && p.Group != config.DefaultGroup {
+		msg = "group " + p.Group + ": " + msg
+	}
+	if p.Severity != SeverityError {
+		msg = string(p.Severity) + ": " + msg
+	}
+	if pos == "" {
+		return msg
+	}
+	return pos + ": " + msg
+}
+
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	lines := make([]string, 0, len(ps))
+	for _, p := range ps {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (ps Problems) Errors() Problems {
+	var out Problems
+	for _, p := range ps {
+		if p.Severity == SeverityError {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+type checker struct {
+	problems Problems
+}
+
+func (c *checker) add(severity Severity, pos config.Position, group string, client string, err error) {
+	c.problems = append(c.problems, Problem{
+		Severity: severity,
+		Message:  err.Error(),
+		Group:    group,
+		Client:   client,
+		File:     pos.File,
+		Line:     pos.Line,
+		Column:   pos.Column,
+	})
+}
+
+func (c *checker) errorf(pos config.Position, group string, client string, err error) {
+	c.add(SeverityError, pos, group, client, err)
+}
+
+func (c *checker) warnf(pos config.Position, group string, client string, err error) {
+	c.add(SeverityWarning, pos, group, client, err)
+}
@@ -1,205 +1,398 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"log/slog"
+	"time"
 
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/audit"
 	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
 	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/format"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/guard"
 )
 
 type Policy struct {
-	Allow []string
-	Deny  []string
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
 }
 
+// ClientSnapshot holds what was read from a client (Policy) and, for writable
+// clients, the merged policy filtered to the categories it accepts (Target).
 type ClientSnapshot struct {
 	Client config.Client
 	Policy Policy
+	Target Policy
 }
 
+// Options control a run. When LockDir is set, each file is locked through
+// lock files in LockDir for the whole run, waiting up to LockTimeout for
+// another syncd process to release it. Locks are taken before the first read,
+// in order of absolute path, so runs sharing files never wait on each other
+// in a cycle.
+//
+// Unless DryRun is set, every added or removed entry is appended to the
+// AuditLog file when it is set.
+//
+// Logger receives a summary of each run, retries and failures, and debug
+// detail of what was read and written; nil discards it.
 type Options struct {
-	DryRun bool
+	DryRun      bool
+	LockDir     string
+	LockTimeout time.Duration
+	AuditLog    string
+	Logger      *slog.Logger
 }
 
-func Run(cfg config.Config, opts Options) (Policy, error) {
-	mode := cfg.Mode
-	if mode == "" {
-		mode = "union"
-	}
-	sortLists := true
-	if cfg.Sort != nil {
-		sortLists = *cfg.Sort
-	}
-
-	snapshots := make([]ClientSnapshot, 0, len(cfg.Clients))
-	for _, client := range cfg.Clients {
-		var err error
-		var allow, deny []string
-		switch strings.ToLower(client.Format) {
-		case "json-object":
-			path := client.AllowPath
-			if path == "" {
-				path = client.DenyPath
-			}
-			if path == "" {
-				return Policy{}, fmt.Errorf("client %s: json-object requires allow_path or deny_path", client.Name)
-			}
-			if client.AllowKey == "" && client.DenyKey == "" {
-				return Policy{}, fmt.Errorf("client %s: json-object requires allow_key or deny_key", client.Name)
-			}
-			if client.AllowKey != "" {
-				allow, err = format.ReadJSONKey(path, client.MissingOK, client.AllowKey)
-				if err != nil {
-					return Policy{}, fmt.Errorf("client %s allow: %w", client.Name, err)
-				}
-			}
-			if client.DenyKey != "" {
-				deny, err = format.ReadJSONKey(path, client.MissingOK, client.DenyKey)
-				if err != nil {
-					return Policy{}, fmt.Errorf("client %s deny: %w", client.Name, err)
-				}
-			}
-		case "json-bool-map":
-			path := client.AllowPath
-			if path == "" {
-				path = client.DenyPath
-			}
-			if path == "" {
-				return Policy{}, fmt.Errorf("client %s: json-bool-map requires allow_path or deny_path", client.Name)
-			}
-			if client.AllowKey == "" {
-				return Policy{}, fmt.Errorf("client %s: json-bool-map requires allow_key", client.Name)
-			}
-			allow, deny, err = format.ReadJSONBoolMap(path, client.MissingOK, client.AllowKey)
-			if err != nil {
-				return Policy{}, fmt.Errorf("client %s allow/deny: %w", client.Name, err)
-			}
-		case "codex-rules":
-			path := client.AllowPath
-			if path == "" {
-				path = client.DenyPath
+// Result describes a run. Changes lists every entry added to or removed
+// from a written client (or that would be, in a dry run). Retries counts how
+// often it re-read and re-merged because a file changed before it could be
+// written.
+//
+// Blocked lists every allow entry a guardrail stopped, and Collapsed every
+// entry the dedupe pass left out of a client, from all groups and projects.
+type Result struct {
+	RunID     string
+	Groups    []GroupResult
+	Projects  []ProjectResult
+	Changes   []Change
+	Retries   int
+	Blocked   []Blocked
+	Collapsed []Collapsed
+}
+
+type GroupResult struct {
+	Name       string
+	Policy     Policy
+	Clients    []ClientSnapshot
+	Categories map[string]entry.Category
+	Origins    Origins
+	Blocked    []Blocked
+	Collapsed  []Collapsed
+}
+
+// maxConflictRetries bounds how often Run starts over after a tool changed
+// one of its files between the read and the write.
+const maxConflictRetries = 3
+
+// ErrConflict reports a file that changed after it was read, so writing the
+// merged result would have discarded the change.
+var ErrConflict = errors.New("file changed since it was read")
+
+// testHookBeforeWrite, when set, runs after all files are read and merged
+// and before anything is written.
+var testHookBeforeWrite func()
+
+// Run reads every client, merges and writes the results. Files are
+// fingerprinted when read and checked again before they are written; if one
+// changed, the whole run is repeated from fresh reads, up to
+// maxConflictRetries times.
+func Run(cfg config.Config, opts Options) (Result, error) {
+	runID := newRunID()
+	log := logger(opts).With("run_id", runID)
+	opts.Logger = log
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		result, err := run(cfg, opts, runID)
+		if errors.Is(err, ErrConflict) && attempt < maxConflictRetries {
+			log.Warn("file changed during sync, re-reading", "attempt", attempt+1, "error", err)
+			continue
+		}
+		if err != nil {
+			attrs := []any{"duration", time.Since(start), "error", err}
+			var clientErr *ClientError
+			if errors.As(err, &clientErr) {
+				attrs = append(attrs, "group", clientErr.Group, "client", clientErr.Client)
 			}
-			if path == "" {
-				return Policy{}, fmt.Errorf("client %s: codex-rules requires allow_path or deny_path", client.Name)
+			log.Error("sync failed", attrs...)
+			return Result{}, err
+		}
+		result.Retries = attempt
+		logSummary(log, result, opts.DryRun, time.Since(start))
+		return result, nil
+	}
+}
+
+func run(cfg config.Config, opts Options, runID string) (Result, error) {
+	result := Result{RunID: runID}
+	files := newFileSet()
+	files.log = opts.Logger
+	files.lockDir = opts.LockDir
+	files.lockTimeout = opts.LockTimeout
+	defer files.release()
+	g, err := newGuard(cfg.Guardrails)
+	if err != nil {
+		return Result{}, err
+	}
+	owned := clientFiles(cfg)
+	found, err := findWorkspaceFiles(cfg, owned)
+	if err != nil {
+		return Result{}, err
+	}
+	paths := make([]string, 0, len(owned))
+	for path := range owned {
+		paths = append(paths, path)
+	}
+	for _, projects := range found {
+		for _, p := range projects {
+			paths = append(paths, p.path)
+		}
+	}
+	if err := files.lockAll(paths); err != nil {
+		return Result{}, err
+	}
+	for _, group := range cfg.AllGroups() {
+		res, err := mergeGroup(files, group, g)
+		if err != nil {
+			return Result{}, groupError(group.Name, err)
+		}
+		result.Groups = append(result.Groups, res)
+		result.Blocked = append(result.Blocked, res.Blocked...)
+		result.Collapsed = append(result.Collapsed, res.Collapsed...)
+	}
+	projects, err := planWorkspaces(files, cfg, found, result.Groups, g)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Projects = projects
+	for _, project := range projects {
+		result.Blocked = append(result.Blocked, project.Blocked...)
+		result.Collapsed = append(result.Collapsed, project.Collapsed...)
+	}
+	for _, c := range result.Collapsed {
+		files.log.Debug("collapsed entry", "group", c.Group, "client", c.Client, "list", c.List, "entry", c.Entry, "by", c.By)
+	}
+	for _, b := range result.Blocked {
+		where := []any{"group", b.Group}
+		if b.Project != "" {
+			where = []any{"project", b.Project}
+		}
+		files.log.Warn("guardrail blocked allow entry", append(where, "entry", b.Entry, "origin", b.Origin, "rule", b.Rule, "action", b.Action)...)
+	}
+	if g.Action == guard.Abort && len(result.Blocked) > 0 {
+		return Result{}, &GuardError{Blocked: result.Blocked}
+	}
+
+	for _, res := range result.Groups {
+		for _, snap := range res.Clients {
+			if !snap.Client.Writes() {
+				continue
 			}
-			allow, deny, err = format.ReadCodexRules(path, client.MissingOK)
+			changes, err := clientChanges(files, res.Name, snap.Client, snap.Policy, snap.Target, res.Origins)
 			if err != nil {
-				return Policy{}, fmt.Errorf("client %s rules: %w", client.Name, err)
+				return Result{}, groupError(res.Name, clientError(res.Name, snap.Client, err))
 			}
-		default:
-			fmtter, err := format.New(client.Format)
-			if err != nil {
-				return Policy{}, fmt.Errorf("client %s: %w", client.Name, err)
+			result.Changes = append(result.Changes, changes...)
+		}
+	}
+	for _, project := range result.Projects {
+		changes, err := clientChanges(files, "", project.Client, project.Policy, project.Target, project.Origins)
+		if err != nil {
+			return Result{}, fmt.Errorf("project %s: %w", project.Dir, err)
+		}
+		result.Changes = append(result.Changes, changes...)
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if testHookBeforeWrite != nil {
+		testHookBeforeWrite()
+	}
+
+	for _, res := range result.Groups {
+		for _, snap := range res.Clients {
+			if !snap.Client.Writes() {
+				continue
 			}
-			allow, err = fmtter.Read(client.AllowPath, client.MissingOK)
-			if err != nil {
-				return Policy{}, fmt.Errorf("client %s allow: %w", client.Name, err)
+			if err := files.write(snap.Client, snap.Target); err != nil {
+				return Result{}, groupError(res.Name, clientError(res.Name, snap.Client, err))
 			}
-			deny, err = fmtter.Read(client.DenyPath, client.MissingOK)
+		}
+	}
+	for _, project := range result.Projects {
+		if err := files.write(project.Client, project.Target); err != nil {
+			return Result{}, fmt.Errorf("project %s: %w", project.Dir, err)
+		}
+	}
+	// Audit before writing, so a change is never made without its record:
+	// when the log cannot be written, no file is, and the next run retries.
+	if err := files.verify(); err != nil {
+		return Result{}, err
+	}
+	if opts.AuditLog != "" {
+		if err := audit.Append(opts.AuditLog, auditRecords(runID, time.Now().UTC(), result.Changes)); err != nil {
+			return Result{}, fmt.Errorf("audit log: %w", err)
+		}
+	}
+	if err := files.flush(); err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+func mergeGroup(files *fileSet, group config.Group, g *guard.Guard) (GroupResult, error) {
+	mode := group.Mode
+	if mode == "" {
+		mode = "union"
+	}
+	sortLists := true
+	if group.Sort != nil {
+		sortLists = *group.Sort
+	}
+
+	snapshots := make([]ClientSnapshot, 0, len(group.Clients))
+	accepts := make([][]entry.Category, 0, len(group.Clients))
+	categories := map[string]entry.Category{}
+	for _, client := range group.Clients {
+		if err := validateDirection(client); err != nil {
+			return GroupResult{}, err
+		}
+		cats, err := clientAccepts(client)
+		if err != nil {
+			return GroupResult{}, err
+		}
+		accepts = append(accepts, cats)
+		snap := ClientSnapshot{Client: client}
+		if client.Reads() {
+			allow, deny, err := files.read(client)
 			if err != nil {
-				return Policy{}, fmt.Errorf("client %s deny: %w", client.Name, err)
+				return GroupResult{}, clientError(group.Name, client, err)
 			}
-		}
-		snapshots = append(snapshots, ClientSnapshot{
-			Client: client,
-			Policy: Policy{
+			snap.Policy = Policy{
 				Allow: format.Normalize(allow, sortLists),
 				Deny:  format.Normalize(deny, sortLists),
-			},
-		})
+			}
+			files.log.Debug("read client", "group", group.Name, "client", client.Name, "allow", len(snap.Policy.Allow), "deny", len(snap.Policy.Deny))
+			tagEntries(categories, snap.Policy.Allow, cats)
+			tagEntries(categories, snap.Policy.Deny, cats)
+		}
+		snapshots = append(snapshots, snap)
 	}
 
 	var merged Policy
+	origins := newOrigins()
 	switch mode {
 	case "union":
 		for _, snap := range snapshots {
+			origins.add(snap.Client.Name, snap.Policy)
 			merged.Allow = append(merged.Allow, snap.Policy.Allow...)
 			merged.Deny = append(merged.Deny, snap.Policy.Deny...)
 		}
 		merged.Allow = format.Normalize(merged.Allow, sortLists)
 		merged.Deny = format.Normalize(merged.Deny, sortLists)
 	case "authoritative":
-		if cfg.Source == "" {
-			return Policy{}, fmt.Errorf("authoritative mode requires source")
+		if group.Source == "" {
+			return GroupResult{}, fmt.Errorf("authoritative mode requires source")
 		}
 		found := false
 		for _, snap := range snapshots {
-			if snap.Client.Name == cfg.Source {
+			if snap.Client.Name == group.Source && snap.Client.Reads() {
 				merged = snap.Policy
+				origins.add(snap.Client.Name, snap.Policy)
 				found = true
 				break
 			}
 		}
 		if !found {
-			return Policy{}, fmt.Errorf("source %q not found", cfg.Source)
+			return GroupResult{}, fmt.Errorf("source %q not found or not readable", group.Source)
 		}
 	default:
-		return Policy{}, fmt.Errorf("unknown mode %q", mode)
+		return GroupResult{}, fmt.Errorf("unknown mode %q", mode)
 	}
 
-	if opts.DryRun {
-		return merged, nil
+	merged, blocked := guardPolicy(g, merged, origins, categories, sortLists)
+	for i := range blocked {
+		blocked[i].Group = group.Name
+	}
+	files.log.Debug("merged group", "group", group.Name, "mode", mode, "allow", len(merged.Allow), "deny", len(merged.Deny))
+	var collapsed []Collapsed
+	category := func(v string) entry.Category { return categories[v] }
+	for i := range snapshots {
+		client := snapshots[i].Client
+		if !client.Writes() {
+			continue
+		}
+		snapshots[i].Target = filterPolicy(merged, accepts[i], categories)
+		snapshots[i].Target = keepOwn(snapshots[i].Target, snapshots[i].Policy.Allow, blocked, sortLists)
+		if dedupes(group.Dedupe, client) {
+			var c []Collapsed
+			snapshots[i].Target, c = dedupePolicy(group.Name, client.Name, snapshots[i].Target, category)
+			collapsed = append(collapsed, c...)
+		}
 	}
 
-	for _, snap := range snapshots {
-		switch strings.ToLower(snap.Client.Format) {
-		case "json-object":
-			path := snap.Client.AllowPath
-			if path == "" {
-				path = snap.Client.DenyPath
-			}
-			if path == "" {
-				return Policy{}, fmt.Errorf("client %s: json-object requires allow_path or deny_path", snap.Client.Name)
-			}
-			if snap.Client.AllowKey != "" {
-				if err := format.WriteJSONKey(path, snap.Client.AllowKey, merged.Allow); err != nil {
-					return Policy{}, fmt.Errorf("client %s allow write: %w", snap.Client.Name, err)
-				}
+	return GroupResult{Name: group.Name, Policy: merged, Clients: snapshots, Categories: categories, Origins: origins, Blocked: blocked, Collapsed: collapsed}, nil
+}
+
+func clientAccepts(client config.Client) ([]entry.Category, error) {
+	cats := make([]entry.Category, 0, len(client.Accepts))
+	for _, name := range client.Accepts {
+		c, err := entry.ParseCategory(name)
+		if err != nil {
+			return nil, fmt.Errorf("client %s accepts: %w", client.Name, err)
+		}
+		cats = append(cats, c)
+	}
+	return cats, nil
+}
+
+func tagEntries(categories map[string]entry.Category, values []string, accepts []entry.Category) {
+	for _, v := range values {
+		if _, ok := categories[v]; ok {
+			continue
+		}
+		categories[v] = entry.ClassifyFor(v, accepts)
+	}
+}
+
+// filterPolicy keeps only entries in the accepted categories. A client with no
+// accepts list receives everything.
+func filterPolicy(policy Policy, accepts []entry.Category, categories map[string]entry.Category) Policy {
+	if len(accepts) == 0 {
+		return policy
+	}
+	keep := func(values []string) []string {
+		out := make([]string, 0, len(values))
+		for _, v := range values {
+			c, ok := categories[v]
+			if !ok {
+				c, _ = entry.Classify(v)
 			}
-			if snap.Client.DenyKey != "" {
-				if err := format.WriteJSONKey(path, snap.Client.DenyKey, merged.Deny); err != nil {
-					return Policy{}, fmt.Errorf("client %s deny write: %w", snap.Client.Name, err)
+			for _, a := range accepts {
+				if c == a {
+					out = append(out, v)
+					break
 				}
 			}
-		case "json-bool-map":
-			path := snap.Client.AllowPath
-			if path == "" {
-				path = snap.Client.DenyPath
-			}
-			if path == "" {
-				return Policy{}, fmt.Errorf("client %s: json-bool-map requires allow_path or deny_path", snap.Client.Name)
-			}
-			if snap.Client.AllowKey == "" {
-				return Policy{}, fmt.Errorf("client %s: json-bool-map requires allow_key", snap.Client.Name)
-			}
-			if err := format.WriteJSONBoolMap(path, snap.Client.AllowKey, merged.Allow, merged.Deny); err != nil {
-				return Policy{}, fmt.Errorf("client %s allow/deny write: %w", snap.Client.Name, err)
-			}
-		case "codex-rules":
-			path := snap.Client.AllowPath
-			if path == "" {
-				path = snap.Client.DenyPath
-			}
-			if path == "" {
-				return Policy{}, fmt.Errorf("client %s: codex-rules requires allow_path or deny_path", snap.Client.Name)
-			}
-			if err := format.WriteCodexRules(path, merged.Allow, merged.Deny); err != nil {
-				return Policy{}, fmt.Errorf("client %s rules write: %w", snap.Client.Name, err)
-			}
-		default:
-			fmtter, err := format.New(snap.Client.Format)
-			if err != nil {
-				return Policy{}, fmt.Errorf("client %s: %w", snap.Client.Name, err)
-			}
-			if err := fmtter.Write(snap.Client.AllowPath, merged.Allow); err != nil {
-				return Policy{}, fmt.Errorf("client %s allow write: %w", snap.Client.Name, err)
-			}
-			if err := fmtter.Write(snap.Client.DenyPath, merged.Deny); err != nil {
-				return Policy{}, fmt.Errorf("client %s deny write: %w", snap.Client.Name, err)
-			}
 		}
+		return out
 	}
+	return Policy{Allow: keep(policy.Allow), Deny: keep(policy.Deny)}
+}
 
-	return merged, nil
+// ClientError attributes a failed run to the client whose files could not be
+// read or written. Its message is that of Err.
+type ClientError struct {
+	Group  string
+	Client string
+	Err    error
+}
+
+func (e *ClientError) Error() string { return e.Err.Error() }
+
+func (e *ClientError) Unwrap() error { return e.Err }
+
+func clientError(group string, client config.Client, err error) error {
+	return &ClientError{Group: group, Client: client.Name, Err: err}
+}
+
+func groupError(name string, err error) error {
+	if name == config.DefaultGroup {
+		return err
+	}
+	return fmt.Errorf("group %s: %w", name, err)
 }
@@ -3,62 +3,346 @@ package sync
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
 	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/format"
 )
 
+// Validate checks the config and the files it points at, returning every
+// error-level problem from Check, or nil.
 func Validate(cfg config.Config) error {
-	for _, client := range cfg.Clients {
-		if err := validateClient(client); err != nil {
-			return err
-		}
+	if problems := Check(cfg).Errors(); len(problems) > 0 {
+		return problems
 	}
 	return nil
 }
 
-func validateClient(client config.Client) error {
+// Check reports every problem with the config and the files it points at:
+// missing or unknown settings, duplicate names, sources that match no client,
+// invalid guardrails, existing files that do not parse with their format or
+// hold the wrong types at their keys, and files that will be written but are
+// not writable.
+func Check(cfg config.Config) Problems {
+	c := &checker{}
+	seen := map[string]bool{}
+	if len(cfg.Clients) > 0 {
+		seen[config.DefaultGroup] = true
+	}
+	for _, group := range cfg.Groups {
+		if group.Name == "" {
+			c.errorf(group.Pos.Of(""), "", "", fmt.Errorf("group without name"))
+			continue
+		}
+		if seen[group.Name] {
+			c.errorf(group.Pos.Of("name"), "", "", fmt.Errorf("duplicate group %q", group.Name))
+		}
+		seen[group.Name] = true
+	}
+	for _, group := range cfg.AllGroups() {
+		c.checkGroup(group)
+	}
+	owned := clientFiles(cfg)
+	for i, ws := range cfg.Workspaces {
+		c.checkWorkspace(i, ws, seen, owned)
+	}
+	if _, err := newGuard(cfg.Guardrails); err != nil {
+		c.errorf(cfg.Guardrails.Pos.Of("action"), "", "", err)
+	}
+	c.checkWriteTargets(cfg)
+	return c.problems
+}
+
+func (c *checker) checkWorkspace(i int, ws config.Workspace, groups map[string]bool, owned map[string]bool) {
+	before := len(c.problems)
+	fail := func(field string, err error) {
+		c.errorf(ws.Pos.Of(field), "", "", fmt.Errorf("workspace %d: %w", i+1, err))
+	}
+	if len(ws.Roots) == 0 {
+		fail("roots", fmt.Errorf("workspace requires roots"))
+	}
+	switch strings.ToLower(ws.Mode) {
+	case "", "union", "authoritative":
+	default:
+		fail("mode", fmt.Errorf("unknown mode %q", ws.Mode))
+	}
+	switch strings.ToLower(ws.Policy) {
+	case "", config.WorkspacePolicyUser:
+		name := ws.Group
+		if name == "" {
+			name = config.DefaultGroup
+		}
+		if !groups[name] {
+			fail("group", fmt.Errorf("group %q not found", name))
+		}
+	case config.WorkspacePolicyProject:
+	default:
+		fail("policy", fmt.Errorf("unknown policy %q (want user or project)", ws.Policy))
+	}
+	if len(c.problems) > before {
+		return
+	}
+	files, err := findProjectFiles(ws, owned)
+	if err != nil {
+		fail("roots", err)
+		return
+	}
+	for _, f := range files {
+		client := workspaceClient(ws, f.path)
+		client.Pos = config.Positions{"": ws.Pos.Of("roots")}
+		c.checkContent("", client)
+	}
+}
+
+func (c *checker) checkGroup(group config.Group) {
+	names := map[string]bool{}
+	for _, client := range group.Clients {
+		if client.Name != "" && names[client.Name] {
+			c.errorf(client.Pos.Of("name"), group.Name, client.Name, fmt.Errorf("duplicate client %q", client.Name))
+		}
+		names[client.Name] = true
+		c.checkClient(group.Name, client)
+	}
+	switch strings.ToLower(group.Mode) {
+	case "", "union":
+	case "authoritative":
+		if err := validateSource(group); err != nil {
+			field := "source"
+			if group.Source == "" {
+				field = "mode"
+			}
+			c.errorf(group.Pos.Of(field), group.Name, "", err)
+		}
+	default:
+		c.errorf(group.Pos.Of("mode"), group.Name, "", fmt.Errorf("unknown mode %q", group.Mode))
+	}
+}
+
+func validateSource(group config.Group) error {
+	if group.Source == "" {
+		return fmt.Errorf("authoritative mode requires source")
+	}
+	for _, client := range group.Clients {
+		if client.Name != group.Source {
+			continue
+		}
+		if !client.Reads() {
+			return fmt.Errorf("source %q has direction %q and is never read", group.Source, client.Direction)
+		}
+		return nil
+	}
+	return fmt.Errorf("source %q not found", group.Source)
+}
+
+// checkWriteTargets rejects two clients writing the same file, or the same
+// key of a shared JSON file, since the last write would silently win.
+func (c *checker) checkWriteTargets(cfg config.Config) {
+	owners := map[string]string{}
+	for _, group := range cfg.AllGroups() {
+		for _, client := range group.Clients {
+			if !client.Writes() {
+				continue
+			}
+			owner := group.Name + "/" + client.Name
+			for _, target := range writeTargets(client) {
+				if prev, ok := owners[target]; ok && prev != owner {
+					c.errorf(client.Pos.Of(""), group.Name, client.Name, fmt.Errorf("clients %s and %s both write %s", prev, owner, target))
+					continue
+				}
+				owners[target] = owner
+			}
+		}
+	}
+}
+
+func writeTargets(client config.Client) []string {
 	switch strings.ToLower(client.Format) {
 	case "json-object":
-		path := primaryPath(client)
-		if path == "" {
-			return fmt.Errorf("client %s: json-object requires allow_path or deny_path", client.Name)
+		var targets []string
+		if writesList(client, "allow") {
+			targets = append(targets, fileKey(allowFile(client))+" key "+client.AllowKey)
+		}
+		if writesList(client, "deny") {
+			targets = append(targets, fileKey(denyFile(client))+" key "+client.DenyKey)
+		}
+		return targets
+	case "json-bool-map":
+		targets := []string{fileKey(allowFile(client)) + " key " + client.AllowKey}
+		if fileKey(denyFile(client)) != fileKey(allowFile(client)) {
+			targets = append(targets, fileKey(denyFile(client))+" key "+boolMapDenyKey(client))
+		}
+		return targets
+	case "codex-rules":
+		return []string{fileKey(primaryPath(client))}
+	default:
+		var targets []string
+		for _, p := range []string{client.AllowPath, client.DenyPath} {
+			if p != "" {
+				targets = append(targets, fileKey(p))
+			}
+		}
+		return targets
+	}
+}
+
+func (c *checker) checkClient(group string, client config.Client) {
+	before := len(c.problems)
+	fail := func(field string, err error) {
+		c.errorf(client.Pos.Of(field), group, client.Name, err)
+	}
+	if client.Name == "" {
+		fail("", fmt.Errorf("client without name"))
+	}
+	if err := validateDirection(client); err != nil {
+		fail("direction", err)
+	}
+	if _, err := clientAccepts(client); err != nil {
+		fail("accepts", err)
+	}
+	switch strings.ToLower(client.Format) {
+	case "json-object":
+		if primaryPath(client) == "" {
+			fail("", fmt.Errorf("client %s: json-object requires allow_path or deny_path", client.Name))
 		}
 		if client.AllowKey == "" && client.DenyKey == "" {
-			return fmt.Errorf("client %s: json-object requires allow_key or deny_key", client.Name)
+			fail("", fmt.Errorf("client %s: json-object requires allow_key or deny_key", client.Name))
 		}
-		return validatePathExists(client, path)
 	case "json-bool-map":
-		path := primaryPath(client)
-		if path == "" {
-			return fmt.Errorf("client %s: json-bool-map requires allow_path or deny_path", client.Name)
+		if primaryPath(client) == "" {
+			fail("", fmt.Errorf("client %s: json-bool-map requires allow_path or deny_path", client.Name))
 		}
 		if client.AllowKey == "" {
-			return fmt.Errorf("client %s: json-bool-map requires allow_key", client.Name)
+			fail("", fmt.Errorf("client %s: json-bool-map requires allow_key", client.Name))
 		}
-		return validatePathExists(client, path)
 	case "codex-rules":
-		path := primaryPath(client)
-		if path == "" {
-			return fmt.Errorf("client %s: codex-rules requires allow_path or deny_path", client.Name)
+		if primaryPath(client) == "" {
+			fail("", fmt.Errorf("client %s: codex-rules requires allow_path or deny_path", client.Name))
 		}
-		return validatePathExists(client, path)
+	case "":
+		fail("", fmt.Errorf("client %s: format required", client.Name))
 	default:
-		if _, err := format.New(client.Format); err != nil {
-			return fmt.Errorf("client %s: %w", client.Name, err)
+		if _, err := format.New(client.Format, nil); err != nil {
+			fail("format", fmt.Errorf("client %s: %w", client.Name, err))
 		}
 		if client.AllowPath == "" || client.DenyPath == "" {
-			return fmt.Errorf("client %s: allow_path and deny_path required", client.Name)
+			fail("", fmt.Errorf("client %s: allow_path and deny_path required", client.Name))
+		}
+	}
+	if len(c.problems) > before {
+		return
+	}
+	c.checkKeyedPaths(group, client)
+	for _, field := range []string{"allow_path", "deny_path"} {
+		path := client.AllowPath
+		if field == "deny_path" {
+			path = client.DenyPath
+			if path == "" || fileKey(path) == fileKey(client.AllowPath) {
+				continue
+			}
+		}
+		if path == "" {
+			continue
+		}
+		if err := validatePathExists(client, path); err != nil {
+			fail(field, err)
+		}
+	}
+	c.checkContent(group, client)
+}
+
+// checkKeyedPaths warns when a keyed JSON client sets both paths, whether in
+// the client itself or through a template: identical paths are redundant, and
+// distinct paths split allow and deny across files.
+func (c *checker) checkKeyedPaths(group string, client config.Client) {
+	switch strings.ToLower(client.Format) {
+	case "json-object", "json-bool-map":
+	default:
+		return
+	}
+	if client.AllowPath == "" || client.DenyPath == "" {
+		return
+	}
+	if fileKey(client.AllowPath) == fileKey(client.DenyPath) {
+		c.warnf(client.Pos.Of("deny_path"), group, client.Name, fmt.Errorf("client %s: deny_path is the same as allow_path and can be omitted", client.Name))
+		return
+	}
+	c.warnf(client.Pos.Of("deny_path"), group, client.Name, fmt.Errorf("client %s: allow list is kept in %s and deny list in %s", client.Name, client.AllowPath, client.DenyPath))
+}
+
+// checkContent parses the client's existing files with its format, which
+// also checks that configured keys hold lists (or maps), and checks that
+// files the client will write can be written.
+func (c *checker) checkContent(group string, client config.Client) {
+	probe := client
+	probe.MissingOK = true
+	if _, _, err := readClient(probe); err != nil {
+		c.errorf(client.Pos.Of("allow_path"), group, client.Name, err)
+	}
+	if !client.Writes() {
+		return
+	}
+	for _, field := range []string{"allow_path", "deny_path"} {
+		path := client.AllowPath
+		if field == "deny_path" {
+			path = client.DenyPath
+			if fileKey(path) == fileKey(client.AllowPath) {
+				continue
+			}
+		}
+		if path == "" {
+			continue
+		}
+		if err := checkWritable(path); err != nil {
+			c.errorf(client.Pos.Of(field), group, client.Name, fmt.Errorf("client %s: %s not writable: %v", client.Name, path, err))
 		}
-		if err := validatePathExists(client, client.AllowPath); err != nil {
+	}
+}
+
+// checkWritable opens an existing file for writing without truncating it, or
+// creates and removes a probe file in the nearest existing parent directory.
+func checkWritable(path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("is a directory")
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
 			return err
 		}
-		if err := validatePathExists(client, client.DenyPath); err != nil {
+		return f.Close()
+	}
+	dir := filepath.Dir(path)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
 			return err
 		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".syncd-probe-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+func validateDirection(client config.Client) error {
+	switch strings.ToLower(client.Direction) {
+	case "", config.DirectionRead, config.DirectionWrite, config.DirectionBoth:
+		return nil
+	default:
+		return fmt.Errorf("client %s: unknown direction %q (want read, write or both)", client.Name, client.Direction)
 	}
-	return nil
 }
 
 func primaryPath(client config.Client) string {
@@ -68,8 +352,42 @@ func primaryPath(client config.Client) string {
 	return client.DenyPath
 }
 
+// allowFile and denyFile are the files a keyed JSON client reads and writes
+// its allow and deny lists in; either falls back to the other when unset.
+func allowFile(client config.Client) string {
+	return primaryPath(client)
+}
+
+func denyFile(client config.Client) string {
+	if client.DenyPath != "" {
+		return client.DenyPath
+	}
+	return client.AllowPath
+}
+
+// writesList reports whether writing client stores its allow or deny list:
+// json-object clients only write the lists they have a key for.
+func writesList(client config.Client, list string) bool {
+	if !strings.EqualFold(client.Format, "json-object") {
+		return true
+	}
+	if list == "allow" {
+		return client.AllowKey != ""
+	}
+	return client.DenyKey != ""
+}
+
+// boolMapDenyKey is the key holding deny entries when a json-bool-map client
+// keeps them in a separate file: deny_key if set, otherwise allow_key.
+func boolMapDenyKey(client config.Client) string {
+	if client.DenyKey != "" {
+		return client.DenyKey
+	}
+	return client.AllowKey
+}
+
 func validatePathExists(client config.Client, path string) error {
-	if client.MissingOK {
+	if client.MissingOK || !client.Reads() {
 		return nil
 	}
 	if _, err := os.Stat(path); err != nil {
@@ -0,0 +1,231 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/config"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/entry"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/format"
+	"github.com/hongkongkiwi/codex-claude-allow-deny-sync/internal/guard"
+)
+
+const defaultWorkspaceDepth = 3
+
+type ProjectResult struct {
+	Dir       string
+	Client    config.Client
+	Policy    Policy
+	Target    Policy
+	Origins   Origins
+	Blocked   []Blocked
+	Collapsed []Collapsed
+}
+
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// findWorkspaceFiles finds the project files of each workspace in cfg,
+// leaving out files in owned.
+func findWorkspaceFiles(cfg config.Config, owned map[string]bool) ([][]projectFile, error) {
+	found := make([][]projectFile, len(cfg.Workspaces))
+	for i, ws := range cfg.Workspaces {
+		files, err := findProjectFiles(ws, owned)
+		if err != nil {
+			return nil, fmt.Errorf("workspace %d: %w", i+1, err)
+		}
+		found[i] = files
+	}
+	return found, nil
+}
+
+// planWorkspaces plans the project files found for each workspace, as
+// returned by findWorkspaceFiles.
+func planWorkspaces(fs *fileSet, cfg config.Config, found [][]projectFile, groups []GroupResult, g *guard.Guard) ([]ProjectResult, error) {
+	sortLists := true
+	if cfg.Sort != nil {
+		sortLists = *cfg.Sort
+	}
+	var out []ProjectResult
+	for i, ws := range cfg.Workspaces {
+		base, origins, err := workspacePolicy(ws, groups, sortLists)
+		if err != nil {
+			return nil, fmt.Errorf("workspace %d: %w", i+1, err)
+		}
+		for _, f := range found[i] {
+			client := workspaceClient(ws, f.path)
+			allow, deny, err := fs.read(client)
+			if err != nil {
+				return nil, fmt.Errorf("project %s: %w", f.dir, err)
+			}
+			res := ProjectResult{
+				Dir:    f.dir,
+				Client: client,
+				Policy: Policy{
+					Allow: format.Normalize(allow, sortLists),
+					Deny:  format.Normalize(deny, sortLists),
+				},
+				Target:  base,
+				Origins: origins,
+			}
+			if !strings.EqualFold(ws.Mode, "authoritative") {
+				res.Target = Policy{
+					Allow: format.Normalize(append(append([]string{}, res.Policy.Allow...), base.Allow...), sortLists),
+					Deny:  format.Normalize(append(append([]string{}, res.Policy.Deny...), base.Deny...), sortLists),
+				}
+			}
+			var blocked []Blocked
+			res.Target, blocked = guardPolicy(g, res.Target, res.Origins, nil, sortLists)
+			res.Target = keepOwn(res.Target, res.Policy.Allow, blocked, sortLists)
+			for _, b := range blocked {
+				// The project's own entries are kept and never spread.
+				if b.Action == guard.Block && slices.Contains(res.Policy.Allow, b.Entry) {
+					continue
+				}
+				b.Project = f.path
+				res.Blocked = append(res.Blocked, b)
+			}
+			if dedupes(cfg.Dedupe, client) {
+				res.Target, res.Collapsed = dedupePolicy("", client.Name, res.Target, func(v string) entry.Category {
+					c, _ := entry.Classify(v)
+					return c
+				})
+			}
+			fs.log.Debug("planned project", "dir", f.dir, "file", f.path, "allow", len(res.Target.Allow), "deny", len(res.Target.Deny))
+			out = append(out, res)
+		}
+	}
+	return out, nil
+}
+
+// workspacePolicy returns the policy applied to a workspace's projects and
+// where its entries came from: the group's clients, or "workspace" for a
+// project policy.
+func workspacePolicy(ws config.Workspace, groups []GroupResult, sortLists bool) (Policy, Origins, error) {
+	switch strings.ToLower(ws.Policy) {
+	case "", config.WorkspacePolicyUser:
+		name := ws.Group
+		if name == "" {
+			name = config.DefaultGroup
+		}
+		for _, g := range groups {
+			if g.Name == name {
+				return g.Policy, g.Origins, nil
+			}
+		}
+		return Policy{}, Origins{}, fmt.Errorf("group %q not found", name)
+	case config.WorkspacePolicyProject:
+		policy := Policy{
+			Allow: format.Normalize(ws.Allow, sortLists),
+			Deny:  format.Normalize(ws.Deny, sortLists),
+		}
+		origins := newOrigins()
+		origins.add("workspace", policy)
+		return policy, origins, nil
+	default:
+		return Policy{}, Origins{}, fmt.Errorf("unknown policy %q (want user or project)", ws.Policy)
+	}
+}
+
+func workspaceClient(ws config.Workspace, path string) config.Client {
+	allowKey, denyKey := ws.AllowKey, ws.DenyKey
+	if allowKey == "" && denyKey == "" {
+		allowKey, denyKey = "permissions.allow", "permissions.deny"
+	}
+	return config.Client{
+		Name:      path,
+		Format:    "json-object",
+		AllowPath: path,
+		AllowKey:  allowKey,
+		DenyKey:   denyKey,
+		MissingOK: true,
+		Direction: config.DirectionWrite,
+	}
+}
+
+type projectFile struct {
+	dir  string
+	path string
+}
+
+// clientFiles returns the files configured clients read and write, which
+// workspaces leave to those clients.
+func clientFiles(cfg config.Config) map[string]bool {
+	owned := map[string]bool{}
+	for _, group := range cfg.AllGroups() {
+		for _, client := range group.Clients {
+			for _, p := range []string{client.AllowPath, client.DenyPath} {
+				if p != "" {
+					owned[fileKey(p)] = true
+				}
+			}
+		}
+	}
+	return owned
+}
+
+// findProjectFiles walks each root up to MaxDepth directories deep and returns
+// the existing settings files that no client in owned handles, such as
+// ~/.claude/settings.json under a root of ~. Hidden directories, node_modules
+// and vendor are not descended into.
+func findProjectFiles(ws config.Workspace, owned map[string]bool) ([]projectFile, error) {
+	depth := ws.MaxDepth
+	if depth <= 0 {
+		depth = defaultWorkspaceDepth
+	}
+	names := ws.Files
+	if len(names) == 0 {
+		names = config.DefaultWorkspaceFiles
+	}
+	if len(ws.Roots) == 0 {
+		return nil, fmt.Errorf("workspace requires roots")
+	}
+	var out []projectFile
+	for _, root := range ws.Roots {
+		if root == "" {
+			return nil, fmt.Errorf("empty root")
+		}
+		root = filepath.Clean(root)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root && os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != root && (strings.HasPrefix(d.Name(), ".") || skippedDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			for _, name := range names {
+				candidate := filepath.Join(path, filepath.FromSlash(name))
+				if owned[fileKey(candidate)] {
+					continue
+				}
+				if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+					out = append(out, projectFile{dir: path, path: candidate})
+				}
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel != "." && strings.Count(rel, string(filepath.Separator))+1 >= depth {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scan %s: %w", root, err)
+		}
+	}
+	return out, nil
+}